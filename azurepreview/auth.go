@@ -0,0 +1,815 @@
+package azurepreview
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/cli"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// getAuthorizer returns a bearer authorizer which refreshes the underlying
+// token before every request whenever it is close to expiring. When auxiliary
+// tenants are configured, the authorizer also sends a token for each of them
+// in the x-ms-authorization-auxiliary header.
+func (c *Config) getAuthorizer(env azure.Environment) (autorest.Authorizer, diag.Diagnostics) {
+	if len(c.AuxiliaryTenantIDs) > 0 {
+		token, diags := c.getMultiTenantToken(env)
+		if diags.HasError() {
+			return nil, diags
+		}
+
+		return autorest.NewMultiTenantServicePrincipalTokenAuthorizer(token), nil
+	}
+
+	token, diags := c.getToken(env)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	return autorest.NewBearerAuthorizer(token), nil
+}
+
+// lazyAuthorizer defers authentication until the first request is prepared,
+// so that configuring the provider never needs network access. A failed
+// attempt is not cached, and is retried by the next request.
+type lazyAuthorizer struct {
+	getAuthorizer func() (autorest.Authorizer, diag.Diagnostics)
+
+	lock       sync.Mutex
+	authorizer autorest.Authorizer
+}
+
+func (a *lazyAuthorizer) WithAuthorization() autorest.PrepareDecorator {
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			authorizer, err := a.resolve()
+			if err != nil {
+				return r, err
+			}
+
+			return authorizer.WithAuthorization()(p).Prepare(r)
+		})
+	}
+}
+
+func (a *lazyAuthorizer) resolve() (autorest.Authorizer, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.authorizer != nil {
+		return a.authorizer, nil
+	}
+
+	authorizer, diags := a.getAuthorizer()
+	if diags.HasError() {
+		return nil, diagnosticsError(diags)
+	}
+
+	a.authorizer = authorizer
+
+	return authorizer, nil
+}
+
+// authenticationError is returned by every request while no token can be
+// obtained. It is never retried, since the credentials will not start
+// working between attempts.
+type authenticationError struct {
+	message string
+}
+
+func (e *authenticationError) Error() string {
+	return e.message
+}
+
+// isAuthenticationError reports whether err, or any error it wraps, is a
+// failure to obtain a token.
+func isAuthenticationError(err error) bool {
+	var authErr *authenticationError
+	return errors.As(err, &authErr) || autorest.IsTokenRefreshError(err)
+}
+
+// diagnosticsError flattens the errors in diags into a single
+// authenticationError.
+func diagnosticsError(diags diag.Diagnostics) error {
+	messages := make([]string, 0)
+	for _, d := range diags {
+		if d.Severity != diag.Error {
+			continue
+		}
+
+		if d.Detail != "" {
+			messages = append(messages, fmt.Sprintf("%s\n\n%s", d.Summary, d.Detail))
+		} else {
+			messages = append(messages, d.Summary)
+		}
+	}
+
+	return &authenticationError{message: strings.Join(messages, "\n\n")}
+}
+
+// authMethod is a single link in the credential chain. skipReason explains
+// why the method cannot be used with the current configuration, and is empty
+// when the method should be used.
+//
+// getMultiTenantToken is only set for the methods which can also authenticate
+// against auxiliary tenants.
+type authMethod struct {
+	name                string
+	skipReason          string
+	getToken            func(env azure.Environment) (adal.OAuthTokenProvider, error)
+	getMultiTenantToken func(env azure.Environment) (adal.MultitenantOAuthTokenProvider, error)
+}
+
+// authMethods returns the credential chain in the order it is evaluated. The
+// first usable method is always the one used: if it fails, authentication
+// fails rather than falling back to the next method.
+func (c *Config) authMethods() []authMethod {
+	return []authMethod{
+		{
+			name: "Client Certificate",
+			skipReason: authSkipReason(c.UseClientCertificate, "use_client_certificate",
+				authSetting{[]string{"client_certificate_path", "client_certificate"}, c.ClientCertificatePath + c.ClientCertificate},
+				authSetting{[]string{"client_id"}, c.ClientID},
+				authSetting{[]string{"tenant_id"}, c.TenantID}),
+			getToken:            c.getClientCertificateToken,
+			getMultiTenantToken: c.getMultiTenantClientCertificateToken,
+		},
+		{
+			name: "Client Secret",
+			skipReason: authSkipReason(c.UseClientSecret, "use_client_secret",
+				authSetting{[]string{"client_secret"}, c.ClientSecret},
+				authSetting{[]string{"client_id"}, c.ClientID},
+				authSetting{[]string{"tenant_id"}, c.TenantID}),
+			getToken:            c.getClientSecretToken,
+			getMultiTenantToken: c.getMultiTenantClientSecretToken,
+		},
+		{
+			name: "OIDC",
+			skipReason: authSkipReason(c.UseOIDC, "use_oidc",
+				authSetting{[]string{"client_id"}, c.ClientID},
+				authSetting{[]string{"tenant_id"}, c.TenantID}),
+			getToken: c.getOIDCToken,
+		},
+		{
+			name:       "Managed Identity",
+			skipReason: authSkipReason(c.UseMSI, "use_msi"),
+			getToken:   c.getMSIToken,
+		},
+		{
+			name:       "Azure CLI",
+			skipReason: c.cliSkipReason(),
+			getToken:   getCLIToken,
+		},
+	}
+}
+
+// authSetting is a setting required by an authentication method. When it
+// has more than one name, setting any one of them is enough, and value holds
+// whichever was set.
+type authSetting struct {
+	names []string
+	value string
+}
+
+func (s authSetting) String() string {
+	names := make([]string, 0, len(s.names))
+	for _, name := range s.names {
+		names = append(names, fmt.Sprintf("`%s`", name))
+	}
+
+	return strings.Join(names, " or ")
+}
+
+func authSkipReason(enabled bool, flag string, required ...authSetting) string {
+	if !enabled {
+		return fmt.Sprintf("`%s` is not enabled", flag)
+	}
+
+	missing := make([]string, 0)
+	for _, setting := range required {
+		if setting.value == "" {
+			missing = append(missing, setting.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Sprintf("%s not set", strings.Join(missing, ", "))
+	}
+
+	return ""
+}
+
+// cliSkipReason refuses to fall back to the Azure CLI when service principal
+// credentials are partially configured, so that a missing or misspelled
+// setting never silently runs with a developer's own identity.
+func (c *Config) cliSkipReason() string {
+	if reason := authSkipReason(c.UseCLI, "use_cli"); reason != "" {
+		return reason
+	}
+
+	configured := make([]string, 0)
+	for _, setting := range []authSetting{
+		{[]string{"client_id"}, c.ClientID},
+		{[]string{"client_secret"}, c.ClientSecret},
+		{[]string{"client_certificate_path"}, c.ClientCertificatePath},
+		{[]string{"client_certificate"}, c.ClientCertificate},
+	} {
+		if setting.value != "" {
+			configured = append(configured, setting.String())
+		}
+	}
+
+	if len(configured) > 0 {
+		return fmt.Sprintf("service principal credentials are partially configured (%s set)", strings.Join(configured, ", "))
+	}
+
+	return ""
+}
+
+// getAuthMethod returns the first usable method in the credential chain. It
+// only inspects the configuration, and so never needs network access.
+func (c *Config) getAuthMethod() (*authMethod, diag.Diagnostics) {
+	skipped := make([]string, 0)
+
+	for _, method := range c.authMethods() {
+		if method.skipReason != "" {
+			log.Printf("[DEBUG] Skipping %s authentication: %s", method.name, method.skipReason)
+			skipped = append(skipped, fmt.Sprintf("- %s: %s", method.name, method.skipReason))
+			continue
+		}
+
+		if len(c.AuxiliaryTenantIDs) > 0 && method.getMultiTenantToken == nil {
+			return nil, diag.Errorf("`auxiliary_tenant_ids` requires authenticating using a Client Secret or Client Certificate, not %s", method.name)
+		}
+
+		log.Printf("[DEBUG] Authenticating using %s", method.name)
+
+		return &method, nil
+	}
+
+	return nil, diag.Diagnostics{
+		diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "No usable Azure credentials were found",
+			Detail:   fmt.Sprintf("The following authentication methods were tried, in order:\n\n%s", strings.Join(skipped, "\n")),
+		},
+	}
+}
+
+func (c *Config) getToken(env azure.Environment) (adal.OAuthTokenProvider, diag.Diagnostics) {
+	method, diags := c.getAuthMethod()
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	token, err := method.getToken(env)
+	if err != nil {
+		return nil, diag.Errorf("error authenticating using %s: %+v", method.name, err)
+	}
+
+	return token, nil
+}
+
+func (c *Config) getMultiTenantToken(env azure.Environment) (adal.MultitenantOAuthTokenProvider, diag.Diagnostics) {
+	method, diags := c.getAuthMethod()
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	token, err := method.getMultiTenantToken(env)
+	if err != nil {
+		return nil, diag.Errorf("error authenticating using %s: %+v", method.name, err)
+	}
+
+	return token, nil
+}
+
+func (c *Config) getClientSecretToken(env azure.Environment) (adal.OAuthTokenProvider, error) {
+	oauthConfig, err := adal.NewOAuthConfigWithAPIVersion(
+		env.ActiveDirectoryEndpoint,
+		c.TenantID,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	spToken, err := adal.NewServicePrincipalToken(
+		*oauthConfig,
+		c.ClientID,
+		c.ClientSecret,
+		env.TokenAudience)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := c.getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	spToken.SetSender(httpClient)
+
+	err = spToken.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	return spToken, nil
+}
+
+func (c *Config) getClientCertificateToken(env azure.Environment) (adal.OAuthTokenProvider, error) {
+	pfxData, err := c.getClientCertificateData()
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, privateKey, err := adal.DecodePfxCertificateData(pfxData, c.ClientCertificatePassword)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding client certificate: %+v", err)
+	}
+
+	oauthConfig, err := adal.NewOAuthConfigWithAPIVersion(
+		env.ActiveDirectoryEndpoint,
+		c.TenantID,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	spToken, err := adal.NewServicePrincipalTokenFromCertificate(
+		*oauthConfig,
+		c.ClientID,
+		certificate,
+		privateKey,
+		env.TokenAudience)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := c.getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	spToken.SetSender(httpClient)
+
+	err = spToken.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	return spToken, nil
+}
+
+func (c *Config) getMultiTenantClientSecretToken(env azure.Environment) (adal.MultitenantOAuthTokenProvider, error) {
+	oauthConfig, err := adal.NewMultiTenantOAuthConfig(
+		env.ActiveDirectoryEndpoint,
+		c.TenantID,
+		c.AuxiliaryTenantIDs,
+		adal.OAuthOptions{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mtToken, err := adal.NewMultiTenantServicePrincipalToken(
+		oauthConfig,
+		c.ClientID,
+		c.ClientSecret,
+		env.TokenAudience)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := c.getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	mtToken.PrimaryToken.SetSender(httpClient)
+	for _, token := range mtToken.AuxiliaryTokens {
+		token.SetSender(httpClient)
+	}
+
+	err = mtToken.RefreshWithContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return mtToken, nil
+}
+
+func (c *Config) getMultiTenantClientCertificateToken(env azure.Environment) (adal.MultitenantOAuthTokenProvider, error) {
+	pfxData, err := c.getClientCertificateData()
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, privateKey, err := adal.DecodePfxCertificateData(pfxData, c.ClientCertificatePassword)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding client certificate: %+v", err)
+	}
+
+	oauthConfig, err := adal.NewMultiTenantOAuthConfig(
+		env.ActiveDirectoryEndpoint,
+		c.TenantID,
+		c.AuxiliaryTenantIDs,
+		adal.OAuthOptions{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mtToken, err := adal.NewMultiTenantServicePrincipalTokenFromCertificate(
+		oauthConfig,
+		c.ClientID,
+		certificate,
+		privateKey,
+		env.TokenAudience)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := c.getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	mtToken.PrimaryToken.SetSender(httpClient)
+	for _, token := range mtToken.AuxiliaryTokens {
+		token.SetSender(httpClient)
+	}
+
+	err = mtToken.RefreshWithContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return mtToken, nil
+}
+
+// getClientCertificateData returns the PFX encoded client certificate, read
+// either from client_certificate_path or the base64 encoded client_certificate.
+func (c *Config) getClientCertificateData() ([]byte, error) {
+	if c.ClientCertificatePath != "" {
+		pfxData, err := ioutil.ReadFile(c.ClientCertificatePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client certificate %q: %+v", c.ClientCertificatePath, err)
+		}
+
+		return pfxData, nil
+	}
+
+	pfxData, err := base64.StdEncoding.DecodeString(c.ClientCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding base64 encoded client certificate: %+v", err)
+	}
+
+	return pfxData, nil
+}
+
+func (c *Config) getOIDCToken(env azure.Environment) (adal.OAuthTokenProvider, error) {
+	oauthConfig, err := adal.NewOAuthConfigWithAPIVersion(
+		env.ActiveDirectoryEndpoint,
+		c.TenantID,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	spToken, err := adal.NewServicePrincipalTokenWithSecret(
+		*oauthConfig,
+		c.ClientID,
+		env.TokenAudience,
+		&clientAssertionSecret{getAssertion: c.getOIDCAssertion})
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := c.getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	spToken.SetSender(httpClient)
+
+	err = spToken.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	return spToken, nil
+}
+
+const oidcTokenAudience = "api://AzureADTokenExchange"
+
+// getOIDCAssertion returns the federated JWT exchanged for an ARM token. It is
+// read on every token refresh, since federated tokens are usually shorter
+// lived than the access tokens issued for them.
+func (c *Config) getOIDCAssertion() (string, error) {
+	if c.OIDCToken != "" {
+		return c.OIDCToken, nil
+	}
+
+	if c.OIDCTokenFilePath != "" {
+		token, err := ioutil.ReadFile(c.OIDCTokenFilePath)
+		if err != nil {
+			return "", fmt.Errorf("error reading OIDC token %q: %+v", c.OIDCTokenFilePath, err)
+		}
+
+		return strings.TrimSpace(string(token)), nil
+	}
+
+	if c.OIDCRequestURL != "" && c.OIDCRequestToken != "" {
+		httpClient, err := c.getHTTPClient()
+		if err != nil {
+			return "", err
+		}
+
+		return requestOIDCToken(httpClient, c.OIDCRequestURL, c.OIDCRequestToken, c.OIDCAzureServiceConnectionID)
+	}
+
+	return "", fmt.Errorf("one of `oidc_token`, `oidc_token_file_path` or `oidc_request_url` and `oidc_request_token` must be set when `use_oidc` is enabled")
+}
+
+// azureDevOpsOIDCAPIVersion is the version of the Azure DevOps API which
+// issues ID tokens for a pipeline's service connection.
+const azureDevOpsOIDCAPIVersion = "7.1"
+
+// requestOIDCToken requests an ID token from a CI identity provider. With a
+// service connection ID it uses the Azure DevOps SYSTEM_OIDCREQUESTURI
+// protocol, and otherwise the GitHub Actions ACTIONS_ID_TOKEN_REQUEST_URL
+// protocol.
+func requestOIDCToken(httpClient *http.Client, requestURL, requestToken, serviceConnectionID string) (string, error) {
+	method := http.MethodGet
+	if serviceConnectionID != "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error building OIDC token request: %+v", err)
+	}
+
+	query := req.URL.Query()
+	if serviceConnectionID != "" {
+		query.Set("api-version", azureDevOpsOIDCAPIVersion)
+		query.Set("serviceConnectionId", serviceConnectionID)
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		query.Set("audience", oidcTokenAudience)
+	}
+	req.URL.RawQuery = query.Encode()
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", requestToken))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting OIDC token: %+v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error requesting OIDC token: unexpected status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		Value     string `json:"value"`
+		OIDCToken string `json:"oidcToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error parsing OIDC token response: %+v", err)
+	}
+
+	if token.Value != "" {
+		return token.Value, nil
+	}
+
+	if token.OIDCToken != "" {
+		return token.OIDCToken, nil
+	}
+
+	return "", fmt.Errorf("error requesting OIDC token: response did not contain a token")
+}
+
+// clientAssertionSecret authenticates a service principal with a JWT issued
+// by a federated identity provider, using the client assertion grant.
+type clientAssertionSecret struct {
+	getAssertion func() (string, error)
+}
+
+func (s *clientAssertionSecret) SetAuthenticationValues(spt *adal.ServicePrincipalToken, v *url.Values) error {
+	assertion, err := s.getAssertion()
+	if err != nil {
+		return err
+	}
+
+	v.Set("client_assertion", assertion)
+	v.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+
+	return nil
+}
+
+func (s clientAssertionSecret) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("marshalling a client assertion secret is not supported")
+}
+
+func getCLIToken(env azure.Environment) (adal.OAuthTokenProvider, error) {
+	cliToken := newCLITokenProvider(env.TokenAudience, cli.GetTokenFromCLI)
+
+	err := cliToken.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	return cliToken, nil
+}
+
+// newCLITokenProvider returns a token provider for tokens issued by the Azure
+// CLI. The CLI does not expose a refresh grant, so a fresh token is requested
+// from it whenever the cached one is about to expire.
+func newCLITokenProvider(resource string, getToken func(resource string) (*cli.Token, error)) *tokenProvider {
+	return newTokenProvider(resource, func(ctx context.Context, resource string) (*adal.Token, error) {
+		cliToken, err := getToken(resource)
+		if err != nil {
+			return nil, fmt.Errorf("error obtaining token from Azure CLI: %+v", err)
+		}
+
+		adalToken, err := cliToken.ToADALToken()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing token from Azure CLI: %+v", err)
+		}
+
+		return &adalToken, nil
+	})
+}
+
+func (c *Config) getMSIToken(env azure.Environment) (adal.OAuthTokenProvider, error) {
+	httpClient, err := c.getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	msiToken := newMSITokenProvider(httpClient, env.TokenAudience, c.MSIEndpoint, c.ClientID)
+
+	err = msiToken.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	return msiToken, nil
+}
+
+const (
+	msiDefaultEndpoint   = "http://169.254.169.254/metadata/identity/oauth2/token"
+	msiIMDSAPIVersion    = "2018-02-01"
+	msiAppServiceVersion = "2019-08-01"
+)
+
+// newMSITokenProvider returns a token provider for a managed identity. Tokens
+// are requested from the App Service identity endpoint when IDENTITY_ENDPOINT
+// and IDENTITY_HEADER are set, and from the instance metadata service (IMDS)
+// otherwise. An empty clientID selects the system-assigned identity.
+func newMSITokenProvider(httpClient *http.Client, resource, endpoint, clientID string) *tokenProvider {
+	identityHeader := os.Getenv("IDENTITY_HEADER")
+
+	if endpoint == "" {
+		endpoint = os.Getenv("IDENTITY_ENDPOINT")
+	}
+
+	if endpoint == "" {
+		endpoint = msiDefaultEndpoint
+		identityHeader = ""
+	}
+
+	return newTokenProvider(resource, func(ctx context.Context, resource string) (*adal.Token, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error building managed identity token request: %+v", err)
+		}
+
+		query := req.URL.Query()
+		query.Set("resource", resource)
+
+		if clientID != "" {
+			query.Set("client_id", clientID)
+		}
+
+		if identityHeader != "" {
+			query.Set("api-version", msiAppServiceVersion)
+			req.Header.Set("X-IDENTITY-HEADER", identityHeader)
+		} else {
+			query.Set("api-version", msiIMDSAPIVersion)
+			req.Header.Set("Metadata", "true")
+		}
+
+		req.URL.RawQuery = query.Encode()
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error obtaining managed identity token from %q: %+v", endpoint, err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error reading managed identity token response: %+v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error obtaining managed identity token from %q: unexpected status %d: %s", endpoint, resp.StatusCode, body)
+		}
+
+		var token adal.Token
+		if err := json.Unmarshal(body, &token); err != nil {
+			return nil, fmt.Errorf("error parsing managed identity token response: %+v", err)
+		}
+
+		return &token, nil
+	})
+}
+
+// tokenRefreshWithin is how long before expiry a token held by a
+// tokenProvider is replaced, matching the refresh window used by adal.
+const tokenRefreshWithin = 5 * time.Minute
+
+// tokenProvider is an adal.OAuthTokenProvider for credentials without a
+// refresh grant. It caches the token returned by getToken and requests a new
+// one whenever the cached token is about to expire.
+type tokenProvider struct {
+	resource string
+	getToken func(ctx context.Context, resource string) (*adal.Token, error)
+
+	lock  sync.RWMutex
+	token adal.Token
+}
+
+func newTokenProvider(resource string, getToken func(ctx context.Context, resource string) (*adal.Token, error)) *tokenProvider {
+	return &tokenProvider{
+		resource: resource,
+		getToken: getToken,
+	}
+}
+
+func (p *tokenProvider) OAuthToken() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.token.OAuthToken()
+}
+
+func (p *tokenProvider) Refresh() error {
+	return p.RefreshWithContext(context.Background())
+}
+
+func (p *tokenProvider) RefreshWithContext(ctx context.Context) error {
+	return p.RefreshExchangeWithContext(ctx, p.resource)
+}
+
+func (p *tokenProvider) RefreshExchangeWithContext(ctx context.Context, resource string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.refresh(ctx, resource)
+}
+
+func (p *tokenProvider) EnsureFreshWithContext(ctx context.Context) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.token.WillExpireIn(tokenRefreshWithin) {
+		return nil
+	}
+
+	return p.refresh(ctx, p.resource)
+}
+
+func (p *tokenProvider) refresh(ctx context.Context, resource string) error {
+	token, err := p.getToken(ctx, resource)
+	if err != nil {
+		return err
+	}
+
+	p.token = *token
+
+	return nil
+}
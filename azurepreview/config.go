@@ -2,15 +2,17 @@ package azurepreview
 
 import (
 	"context"
+	"net/http"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/services/authorization/mgmt/2015-07-01/authorization"
 	"github.com/Azure/azure-sdk-for-go/services/consumption/mgmt/2019-01-01/consumption"
 	"github.com/Azure/azure-sdk-for-go/services/preview/subscription/mgmt/2019-10-01-preview/subscription"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-11-01/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-05-01/managementgroups"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-06-01/resources"
 	"github.com/Azure/go-autorest/autorest"
-	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/Azure/go-autorest/autorest/azure"
-	"github.com/Azure/go-autorest/autorest/azure/cli"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 )
 
@@ -20,6 +22,39 @@ type Config struct {
 	ClientSecret   string
 	TenantID       string
 	Environment    string
+	MetadataHost   string
+	Retry          RetryPolicy
+	HTTPLogging    bool
+
+	AuxiliaryTenantIDs []string
+
+	SkipCredentialsValidation bool
+
+	ProxyURL          string
+	CACertificatePath string
+	RequestTimeout    time.Duration
+
+	UseClientSecret bool
+
+	UseClientCertificate      bool
+	ClientCertificatePath     string
+	ClientCertificate         string
+	ClientCertificatePassword string
+
+	UseMSI      bool
+	MSIEndpoint string
+
+	UseCLI bool
+
+	UseOIDC           bool
+	OIDCToken         string
+	OIDCTokenFilePath string
+	OIDCRequestURL    string
+	OIDCRequestToken  string
+
+	OIDCAzureServiceConnectionID string
+
+	httpClient *http.Client
 }
 
 type Meta struct {
@@ -27,7 +62,21 @@ type Meta struct {
 	Resources     resources.Client
 	Subscription  subscription.Client
 	Subscriptions subscriptions.Client
+	Tags          resources.TagsClient
 	StopContext   context.Context
+
+	SubscriptionAliases SubscriptionAliasesClient
+	RoleAssignments     authorization.RoleAssignmentsClient
+
+	ManagementGroupEntities      managementgroups.EntitiesClient
+	ManagementGroupSubscriptions managementgroups.SubscriptionsClient
+
+	// Environment is the resolved Azure environment; every client is built
+	// against its ResourceManagerEndpoint.
+	Environment azure.Environment
+
+	subscriptionID string
+	clientOptions  *clientOptions
 }
 
 func (c *Config) Client(userAgent string) (*Meta, diag.Diagnostics) {
@@ -35,84 +84,146 @@ func (c *Config) Client(userAgent string) (*Meta, diag.Diagnostics) {
 		StopContext: context.Background(),
 	}
 
-	authorizer, err := c.getAuthorizer()
+	httpClient, err := c.getHTTPClient()
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	// Unlike the credentials below, a metadata_host is resolved eagerly: every
+	// client needs its endpoints before the first request is made.
+	env, err := c.getEnvironment()
 	if err != nil {
+		if c.MetadataHost != "" {
+			return nil, diag.Errorf("error loading environment from metadata host %q: %+v", c.MetadataHost, err)
+		}
 		return nil, diag.FromErr(err)
 	}
 
-	meta.Budgets = consumption.NewBudgetsClient(c.SubscriptionID)
-	configureClient(&meta.Budgets.Client, userAgent, authorizer)
+	meta.Environment = env
+
+	// Credentials are only checked for a usable method here, without network
+	// access; the token itself is acquired by the first ARM request.
+	if !c.SkipCredentialsValidation {
+		if _, diags := c.getAuthMethod(); diags.HasError() {
+			return nil, diags
+		}
+	}
 
-	meta.Resources = resources.NewClient(c.SubscriptionID)
-	configureClient(&meta.Resources.Client, userAgent, authorizer)
+	authorizer := &lazyAuthorizer{
+		getAuthorizer: func() (autorest.Authorizer, diag.Diagnostics) {
+			return c.getAuthorizer(env)
+		},
+	}
 
-	meta.Subscription = subscription.NewClient()
-	configureClient(&meta.Subscription.Client, userAgent, authorizer)
+	o := &clientOptions{
+		userAgent:  userAgent,
+		authorizer: authorizer,
+		sender:     httpClient,
+		retry:      c.Retry,
+		logging:    c.HTTPLogging,
+	}
 
-	meta.Subscriptions = subscriptions.NewClient()
-	configureClient(&meta.Subscriptions.Client, userAgent, authorizer)
+	meta.subscriptionID = c.SubscriptionID
+	meta.clientOptions = o
+
+	meta.Budgets = meta.BudgetsClient("")
+	meta.Resources = meta.ResourcesClient("")
+
+	meta.Subscription = subscription.NewClientWithBaseURI(env.ResourceManagerEndpoint)
+	configureClient(&meta.Subscription.Client, o)
+
+	meta.Subscriptions = subscriptions.NewClientWithBaseURI(env.ResourceManagerEndpoint)
+	configureClient(&meta.Subscriptions.Client, o)
+
+	// Tags are always addressed by scope, so the client's subscription is
+	// never used.
+	meta.Tags = resources.NewTagsClientWithBaseURI(env.ResourceManagerEndpoint, c.SubscriptionID)
+	configureClient(&meta.Tags.Client, o)
+
+	// Role assignments are only managed by scope, like tags.
+	meta.RoleAssignments = authorization.NewRoleAssignmentsClientWithBaseURI(env.ResourceManagerEndpoint, c.SubscriptionID)
+	configureClient(&meta.RoleAssignments.Client, o)
+
+	meta.SubscriptionAliases = NewSubscriptionAliasesClientWithBaseURI(env.ResourceManagerEndpoint)
+	configureClient(&meta.SubscriptionAliases.Client, o)
+
+	meta.ManagementGroupEntities = managementgroups.NewEntitiesClientWithBaseURI(env.ResourceManagerEndpoint, "", nil, nil, "")
+	configureClient(&meta.ManagementGroupEntities.Client, o)
+
+	meta.ManagementGroupSubscriptions = managementgroups.NewSubscriptionsClientWithBaseURI(env.ResourceManagerEndpoint, "", nil, nil, "")
+	configureClient(&meta.ManagementGroupSubscriptions.Client, o)
 
 	return &meta, nil
 }
 
-func configureClient(client *autorest.Client, userAgent string, authorizer autorest.Authorizer) {
-	client.Authorizer = authorizer
-	client.UserAgent = userAgent
+// BudgetsClient returns a Budgets client scoped to subscriptionID, or to the
+// provider's subscription when it is empty.
+func (m *Meta) BudgetsClient(subscriptionID string) consumption.BudgetsClient {
+	client := consumption.NewBudgetsClientWithBaseURI(m.Environment.ResourceManagerEndpoint, m.getSubscriptionID(subscriptionID))
+	configureClient(&client.Client, m.clientOptions)
+	return client
 }
 
-func (c *Config) getAuthorizer() (autorest.Authorizer, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return nil, err
-	}
-
-	return autorest.NewBearerAuthorizer(token), nil
+// ResourcesClient returns a Resources client scoped to subscriptionID, or to
+// the provider's subscription when it is empty. A new client is returned by
+// every call, so resources read in parallel never share one.
+func (m *Meta) ResourcesClient(subscriptionID string) resources.Client {
+	client := resources.NewClientWithBaseURI(m.Environment.ResourceManagerEndpoint, m.getSubscriptionID(subscriptionID))
+	configureClient(&client.Client, m.clientOptions)
+	return client
 }
 
-func (c *Config) getToken() (*adal.Token, error) {
-	env, err := azure.EnvironmentFromName(c.Environment)
-	if err != nil {
-		return nil, err
+func (m *Meta) getSubscriptionID(subscriptionID string) string {
+	if subscriptionID != "" {
+		return subscriptionID
 	}
 
-	if c.ClientID != "" && c.ClientSecret != "" && c.TenantID != "" {
-		oauthConfig, err := adal.NewOAuthConfigWithAPIVersion(
-			env.ActiveDirectoryEndpoint,
-			c.TenantID,
-			nil,
-		)
-		if err != nil {
-			return nil, err
-		}
+	return m.subscriptionID
+}
 
-		spToken, err := adal.NewServicePrincipalToken(
-			*oauthConfig,
-			c.ClientID,
-			c.ClientSecret,
-			env.ResourceManagerEndpoint)
-		if err != nil {
-			return nil, err
-		}
+// clientOptions holds the settings shared by every ARM client.
+type clientOptions struct {
+	userAgent  string
+	authorizer autorest.Authorizer
+	sender     autorest.Sender
+	retry      RetryPolicy
+	logging    bool
+}
 
-		err = spToken.Refresh()
-		if err != nil {
-			return nil, err
-		}
+func configureClient(client *autorest.Client, o *clientOptions) {
+	client.Authorizer = o.authorizer
+	client.UserAgent = o.userAgent
 
-		adalToken := spToken.Token()
+	client.RetryAttempts = o.retry.MaxAttempts
 
-		return &adalToken, nil
-	}
+	// Long-running operations wait for the Retry-After of the operation, or
+	// PollingDelay, between polls. RetryDuration is only the first delay
+	// after a failed poll, which the SDK doubles without a cap, so it starts
+	// as small as the retry policy's own backoff.
+	client.RetryDuration = retryInitialBackoff
 
-	cliToken, err := cli.GetTokenFromCLI(env.ResourceManagerEndpoint)
-	if err != nil {
-		return nil, err
+	var sender autorest.Sender = http.DefaultClient
+	if o.sender != nil {
+		sender = o.sender
 	}
 
-	adalToken, err := cliToken.ToADALToken()
-	if err != nil {
-		return nil, err
+	// Logging and the retry policy wrap the sender rather than being send
+	// decorators, since the SDK polls long-running operations through the
+	// sender alone. Decorators wrap the ones before them, so logging sees
+	// every attempt.
+	decorators := make([]autorest.SendDecorator, 0)
+	if o.logging {
+		decorators = append(decorators, withRequestLogging())
 	}
 
-	return &adalToken, nil
+	decorators = append(decorators, withRetryPolicy(o.retry))
+
+	client.Sender = autorest.DecorateSender(sender, decorators...)
+
+	// Resource provider registration replaces the SDK's own send decorators,
+	// so that 429 and 5xx responses are retried once, by the retry policy,
+	// instead of being retried again by each nested SDK decorator.
+	client.SendDecorators = []autorest.SendDecorator{
+		withResourceProviderRegistration(o),
+	}
 }
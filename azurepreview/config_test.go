@@ -0,0 +1,386 @@
+package azurepreview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const testSubscriptionID = "00000000-0000-0000-0000-000000000000"
+
+// testARMServer is a fake Azure Resource Manager which also serves the
+// metadata document and token endpoint of its cloud, and records every ARM
+// request it receives.
+type testARMServer struct {
+	*httptest.Server
+	*http.ServeMux
+
+	lock     sync.Mutex
+	requests []*http.Request
+	tags     map[string]interface{}
+	state    string
+}
+
+func newTestARMServer(t *testing.T) *testARMServer {
+	mux := http.NewServeMux()
+	server := &testARMServer{Server: httptest.NewServer(mux), ServeMux: mux, state: "Enabled"}
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/metadata/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"authentication":{"loginEndpoint":"%s/","audiences":["https://management.core.windows.net/"]}}`, server.URL)
+	})
+
+	mux.HandleFunc("/00000000-0000-0000-0000-000000000002/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"arm-token","expires_in":"3600","expires_on":"%d","token_type":"Bearer"}`,
+			time.Now().Add(time.Hour).Unix())
+	})
+
+	mux.HandleFunc("/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		server.lock.Lock()
+		defer server.lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"value":[{"id":"/subscriptions/%[1]s","subscriptionId":"%[1]s","displayName":"example","tenantId":"00000000-0000-0000-0000-000000000002","state":"%[2]s","subscriptionPolicies":{"quotaId":"EnterpriseAgreement_2014-09-01"}}]}`,
+			testSubscriptionID, server.state)
+	})
+
+	mux.HandleFunc("/subscriptions/", func(w http.ResponseWriter, r *http.Request) {
+		server.lock.Lock()
+		server.requests = append(server.requests, r)
+		server.lock.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer arm-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case fmt.Sprintf("/subscriptions/%s", testSubscriptionID):
+			server.lock.Lock()
+			defer server.lock.Unlock()
+
+			fmt.Fprintf(w, `{"id":"/subscriptions/%[1]s","subscriptionId":"%[1]s","displayName":"example","tenantId":"00000000-0000-0000-0000-000000000002","state":"%[2]s","authorizationSource":"RoleBased","subscriptionPolicies":{"locationPlacementId":"Public_2014-09-01","quotaId":"EnterpriseAgreement_2014-09-01","spendingLimit":"Off"}}`,
+				testSubscriptionID, server.state)
+		case fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Resources/tags/default", testSubscriptionID):
+			server.lock.Lock()
+			defer server.lock.Unlock()
+
+			if r.Method == http.MethodPut {
+				var body struct {
+					Properties struct {
+						Tags map[string]interface{} `json:"tags"`
+					} `json:"properties"`
+				}
+
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				server.tags = body.Properties.Tags
+			}
+
+			tags, _ := json.Marshal(server.tags)
+			fmt.Fprintf(w, `{"id":"/subscriptions/%s/providers/Microsoft.Resources/tags/default","name":"default","properties":{"tags":%s}}`, testSubscriptionID, tags)
+		case fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Subscription/enable", testSubscriptionID):
+			server.lock.Lock()
+			defer server.lock.Unlock()
+
+			server.state = "Enabled"
+			fmt.Fprintf(w, `{"value":"%s"}`, testSubscriptionID)
+		default:
+			if strings.HasSuffix(r.URL.Path, "/resources") {
+				fmt.Fprintf(w, `{"value":[{"id":"%s/resourceGroups/example/providers/Microsoft.Network/virtualNetworks/example","name":"example","type":"Microsoft.Network/virtualNetworks","location":"westeurope"}]}`,
+					strings.TrimSuffix(r.URL.Path, "/resources"))
+				return
+			}
+
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":"NotFound","message":"not found"}}`)
+		}
+	})
+
+	return server
+}
+
+func (s *testARMServer) Requests() []*http.Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.requests
+}
+
+// testProviderConfig returns a provider configuration which authenticates
+// against server. Tests which need other settings change the returned map.
+func testProviderConfig(server *testARMServer) map[string]interface{} {
+	return map[string]interface{}{
+		"subscription_id": testSubscriptionID,
+		"client_id":       "00000000-0000-0000-0000-000000000001",
+		"client_secret":   "secret",
+		"tenant_id":       "00000000-0000-0000-0000-000000000002",
+		"metadata_host":   server.URL,
+	}
+}
+
+// testProviderMetaForServer configures the provider against server.
+func testProviderMetaForServer(t *testing.T, server *testARMServer) *Meta {
+	return testProviderMeta(t, testProviderConfig(server))
+}
+
+func testProviderMeta(t *testing.T, raw map[string]interface{}) *Meta {
+	p := Provider()
+
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(raw))
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	return p.Meta().(*Meta)
+}
+
+func TestConfigClient_fakeResourceManager(t *testing.T) {
+	server := newTestARMServer(t)
+
+	meta := testProviderMetaForServer(t, server)
+
+	ctx := context.Background()
+
+	subscription := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{})
+	subscription.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	if diags := resourceAzurePreviewSubscriptionRead(ctx, subscription, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if v := subscription.Get("name").(string); v != "example" {
+		t.Fatalf("expected name %q, got %q", "example", v)
+	}
+
+	resources := schema.TestResourceDataRaw(t, dataSourceAzurePreviewResources().Schema, map[string]interface{}{})
+
+	if diags := dataSourceAzurePreviewResourcesRead(ctx, resources, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if v := resources.Get("resources.0.name").(string); v != "example" {
+		t.Fatalf("expected resource name %q, got %q", "example", v)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests to the fake Resource Manager, got %d", len(requests))
+	}
+
+	for _, r := range requests {
+		if !strings.HasPrefix(r.URL.Path, fmt.Sprintf("/subscriptions/%s", testSubscriptionID)) {
+			t.Fatalf("unexpected request to %s", r.URL)
+		}
+	}
+}
+
+func TestConfigClient_sovereignClouds(t *testing.T) {
+	server, _ := newTestIMDSServer(t, "Metadata", "true")
+
+	for _, env := range []azure.Environment{azure.ChinaCloud, azure.USGovernmentCloud, azure.GermanCloud} {
+		config := &Config{
+			SubscriptionID: testSubscriptionID,
+			Environment:    env.Name,
+			UseMSI:         true,
+			MSIEndpoint:    server.URL,
+		}
+
+		meta, diags := config.Client("terraform-provider-azurepreview")
+		if diags.HasError() {
+			t.Fatalf("err: %+v", diags)
+		}
+
+		if meta.Environment.Name != env.Name {
+			t.Fatalf("expected environment %q, got %q", env.Name, meta.Environment.Name)
+		}
+
+		for name, baseURI := range map[string]string{
+			"Budgets":       meta.Budgets.BaseURI,
+			"Resources":     meta.Resources.BaseURI,
+			"Subscription":  meta.Subscription.BaseURI,
+			"Subscriptions": meta.Subscriptions.BaseURI,
+			"Tags":          meta.Tags.BaseURI,
+
+			"SubscriptionAliases":          meta.SubscriptionAliases.BaseURI,
+			"RoleAssignments":              meta.RoleAssignments.BaseURI,
+			"ManagementGroupEntities":      meta.ManagementGroupEntities.BaseURI,
+			"ManagementGroupSubscriptions": meta.ManagementGroupSubscriptions.BaseURI,
+		} {
+			if baseURI != env.ResourceManagerEndpoint {
+				t.Fatalf("expected %s client in %s to use %q, got %q", name, env.Name, env.ResourceManagerEndpoint, baseURI)
+			}
+		}
+	}
+}
+
+func TestConfigClient_defersAuthentication(t *testing.T) {
+	server, _ := newTestIMDSServer(t, "Metadata", "true")
+	server.Close()
+
+	config := &Config{
+		SubscriptionID: testSubscriptionID,
+		Environment:    "AzurePublicCloud",
+		UseMSI:         true,
+		MSIEndpoint:    server.URL,
+	}
+
+	meta, diags := config.Client("terraform-provider-azurepreview")
+	if diags.HasError() {
+		t.Fatalf("expected configuring the provider not to request a token, got: %+v", diags)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://management.azure.com/subscriptions", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = autorest.Prepare(req, meta.Subscription.WithAuthorization())
+	if err == nil {
+		t.Fatal("expected the first request to fail to authenticate")
+	}
+
+	if !strings.Contains(err.Error(), "error authenticating using Managed Identity") {
+		t.Fatalf("expected a Managed Identity error, got: %s", err)
+	}
+}
+
+func TestConfigClient_authenticationFailureIsNotRetried(t *testing.T) {
+	server := newTestARMServer(t)
+
+	var tokenRequests int32
+	server.HandleFunc("/00000000-0000-0000-0000-000000000009/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client","error_description":"Invalid client secret provided."}`)
+	})
+
+	raw := testProviderConfig(server)
+	raw["client_secret"] = "wrong"
+	raw["tenant_id"] = "00000000-0000-0000-0000-000000000009"
+
+	meta := testProviderMeta(t, raw)
+
+	start := time.Now()
+
+	_, err := meta.Subscriptions.Get(context.Background(), testSubscriptionID)
+	if err == nil {
+		t.Fatal("expected the request to fail to authenticate")
+	}
+
+	if !isAuthenticationError(err) {
+		t.Fatalf("expected an authentication error, got %T: %s", err, err)
+	}
+
+	// The default retry policy would take around 15 seconds to give up.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the authentication failure not to be retried, took %s", elapsed)
+	}
+
+	if n := atomic.LoadInt32(&tokenRequests); n != 1 {
+		t.Fatalf("expected a single token request, got %d", n)
+	}
+
+	if n := len(server.Requests()); n != 0 {
+		t.Fatalf("expected no ARM requests without a token, got %d", n)
+	}
+}
+
+func TestConfigClient_skipCredentialsValidation(t *testing.T) {
+	config := &Config{
+		SubscriptionID: testSubscriptionID,
+		Environment:    "AzurePublicCloud",
+	}
+
+	if _, diags := config.Client("terraform-provider-azurepreview"); !diags.HasError() {
+		t.Fatal("expected an error when no credentials are configured")
+	}
+
+	config.SkipCredentialsValidation = true
+
+	meta, diags := config.Client("terraform-provider-azurepreview")
+	if diags.HasError() {
+		t.Fatalf("expected configuring the provider to succeed offline, got: %+v", diags)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://management.azure.com/subscriptions", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = autorest.Prepare(req, meta.Subscription.WithAuthorization())
+	if err == nil || !strings.Contains(err.Error(), "No usable Azure credentials were found") {
+		t.Fatalf("expected the first request to report the missing credentials, got: %v", err)
+	}
+}
+
+func TestMetaResourcesClient_subscriptionOverride(t *testing.T) {
+	server := newTestARMServer(t)
+
+	meta := testProviderMetaForServer(t, server)
+
+	subscriptionIDs := []string{
+		testSubscriptionID,
+		"11111111-1111-1111-1111-111111111111",
+		"22222222-2222-2222-2222-222222222222",
+	}
+
+	var wg sync.WaitGroup
+	for _, subscriptionID := range subscriptionIDs {
+		wg.Add(1)
+
+		go func(subscriptionID string) {
+			defer wg.Done()
+
+			raw := map[string]interface{}{}
+			if subscriptionID != testSubscriptionID {
+				raw["subscription_id"] = subscriptionID
+			}
+
+			d := schema.TestResourceDataRaw(t, dataSourceAzurePreviewResources().Schema, raw)
+
+			if diags := dataSourceAzurePreviewResourcesRead(context.Background(), d, meta); diags.HasError() {
+				t.Errorf("err: %+v", diags)
+				return
+			}
+
+			if v := d.Get("subscription_id").(string); v != subscriptionID {
+				t.Errorf("expected subscription_id %q, got %q", subscriptionID, v)
+			}
+
+			expected := fmt.Sprintf("/subscriptions/%s/resourceGroups/example/providers/Microsoft.Network/virtualNetworks/example", subscriptionID)
+			if v := d.Get("resources.0.id").(string); v != expected {
+				t.Errorf("expected resource %q, got %q", expected, v)
+			}
+		}(subscriptionID)
+	}
+	wg.Wait()
+
+	if meta.Resources.SubscriptionID != testSubscriptionID {
+		t.Fatalf("expected the provider's Resources client to keep subscription %q, got %q", testSubscriptionID, meta.Resources.SubscriptionID)
+	}
+
+	if n := len(server.Requests()); n != len(subscriptionIDs) {
+		t.Fatalf("expected %d requests, got %d", len(subscriptionIDs), n)
+	}
+}
@@ -18,6 +18,7 @@ func dataSourceAzurePreviewResources() *schema.Resource {
 			"subscription_id": {
 				Type:             schema.TypeString,
 				Optional:         true,
+				Computed:         true,
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
@@ -81,14 +82,10 @@ func dataSourceAzurePreviewResources() *schema.Resource {
 func dataSourceAzurePreviewResourcesRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	client := meta.(*Meta).Resources
+	client := meta.(*Meta).ResourcesClient(d.Get("subscription_id").(string))
 
 	tags := d.Get("tags").(map[string]interface{})
 
-	if v, ok := d.GetOk("subscription_id"); ok {
-		client.SubscriptionID = v.(string)
-	}
-
 	var filters []string
 
 	if v, ok := d.GetOk("type"); ok {
@@ -160,6 +157,7 @@ func dataSourceAzurePreviewResourcesRead(ctx context.Context, d *schema.Resource
 
 	d.SetId(id)
 
+	d.Set("subscription_id", client.SubscriptionID)
 	d.Set("resources", resources)
 
 	return diags
@@ -0,0 +1,97 @@
+package azurepreview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// getEnvironment resolves the Azure environment the provider talks to. When
+// metadata_host is set the endpoints are discovered from the ARM metadata
+// document, which is required for Azure Stack Hub and other custom clouds.
+func (c *Config) getEnvironment() (azure.Environment, error) {
+	if c.MetadataHost != "" {
+		httpClient, err := c.getHTTPClient()
+		if err != nil {
+			return azure.Environment{}, err
+		}
+
+		return environmentFromMetadataHost(httpClient, c.MetadataHost)
+	}
+
+	return azure.EnvironmentFromName(c.Environment)
+}
+
+type environmentMetadata struct {
+	Name            string `json:"name"`
+	GalleryEndpoint string `json:"galleryEndpoint"`
+	GraphEndpoint   string `json:"graphEndpoint"`
+	PortalEndpoint  string `json:"portalEndpoint"`
+	Authentication  struct {
+		LoginEndpoint string   `json:"loginEndpoint"`
+		Audiences     []string `json:"audiences"`
+	} `json:"authentication"`
+}
+
+// environmentFromMetadataHost builds an environment from the document served
+// at /metadata/endpoints. metadataHost is either a host name, which is
+// reached over HTTPS, or a full URL.
+func environmentFromMetadataHost(httpClient *http.Client, metadataHost string) (azure.Environment, error) {
+	endpoint := strings.TrimSuffix(metadataHost, "/")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = fmt.Sprintf("https://%s", endpoint)
+	}
+
+	metadataURL := fmt.Sprintf("%s/metadata/endpoints?api-version=1.0", endpoint)
+
+	resp, err := httpClient.Get(metadataURL)
+	if err != nil {
+		return azure.Environment{}, fmt.Errorf("error retrieving environment metadata from %q: %+v", metadataURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return azure.Environment{}, fmt.Errorf("error reading environment metadata from %q: %+v", metadataURL, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return azure.Environment{}, fmt.Errorf("error retrieving environment metadata from %q: unexpected status %d", metadataURL, resp.StatusCode)
+	}
+
+	var metadata environmentMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return azure.Environment{}, fmt.Errorf("error parsing environment metadata from %q: %+v", metadataURL, err)
+	}
+
+	if metadata.Authentication.LoginEndpoint == "" || len(metadata.Authentication.Audiences) == 0 {
+		return azure.Environment{}, fmt.Errorf("environment metadata from %q does not contain authentication endpoints", metadataURL)
+	}
+
+	name := metadata.Name
+	if name == "" {
+		name = "HybridEnvironment"
+	}
+
+	return azure.Environment{
+		Name:                    name,
+		ManagementPortalURL:     metadata.PortalEndpoint,
+		ResourceManagerEndpoint: fmt.Sprintf("%s/", endpoint),
+		ActiveDirectoryEndpoint: ensureTrailingSlash(metadata.Authentication.LoginEndpoint),
+		GalleryEndpoint:         metadata.GalleryEndpoint,
+		GraphEndpoint:           metadata.GraphEndpoint,
+		TokenAudience:           metadata.Authentication.Audiences[0],
+	}, nil
+}
+
+func ensureTrailingSlash(input string) string {
+	if strings.HasSuffix(input, "/") {
+		return input
+	}
+
+	return fmt.Sprintf("%s/", input)
+}
@@ -0,0 +1,132 @@
+package azurepreview
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testTokenAudience = "https://management.adfs.azurestack.local/00000000-0000-0000-0000-000000000003"
+
+// newTestMetadataServer returns a fake Azure Stack style cloud which serves
+// the ARM metadata document and a token endpoint on the same host.
+func newTestMetadataServer(t *testing.T) (*httptest.Server, *string) {
+	var resource string
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/metadata/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+  "galleryEndpoint": "%[1]s/gallery/",
+  "graphEndpoint": "%[1]s/graph/",
+  "portalEndpoint": "%[1]s/portal/",
+  "authentication": {
+    "loginEndpoint": "%[1]s/adfs",
+    "audiences": ["%[2]s"]
+  }
+}`, server.URL, testTokenAudience)
+	})
+
+	mux.HandleFunc("/adfs/00000000-0000-0000-0000-000000000002/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("err: %s", err)
+		}
+
+		resource = r.PostForm.Get("resource")
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"stack-token","expires_in":"3600","expires_on":"%d","token_type":"Bearer"}`,
+			time.Now().Add(time.Hour).Unix())
+	})
+
+	return server, &resource
+}
+
+func TestConfigGetEnvironment_metadataHost(t *testing.T) {
+	server, _ := newTestMetadataServer(t)
+
+	config := &Config{
+		Environment:  "AzurePublicCloud",
+		MetadataHost: server.URL,
+	}
+
+	env, err := config.getEnvironment()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if expected := server.URL + "/"; env.ResourceManagerEndpoint != expected {
+		t.Fatalf("expected ResourceManagerEndpoint %q, got %q", expected, env.ResourceManagerEndpoint)
+	}
+
+	if expected := server.URL + "/adfs/"; env.ActiveDirectoryEndpoint != expected {
+		t.Fatalf("expected ActiveDirectoryEndpoint %q, got %q", expected, env.ActiveDirectoryEndpoint)
+	}
+
+	if env.TokenAudience != testTokenAudience {
+		t.Fatalf("expected TokenAudience %q, got %q", testTokenAudience, env.TokenAudience)
+	}
+
+	if env.Name != "HybridEnvironment" {
+		t.Fatalf("expected Name %q, got %q", "HybridEnvironment", env.Name)
+	}
+}
+
+func TestConfigGetEnvironment_metadataHostMissingAuthentication(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"galleryEndpoint": "https://gallery.local/"}`)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		MetadataHost: server.URL,
+	}
+
+	if _, err := config.getEnvironment(); err == nil {
+		t.Fatal("expected an error for metadata without authentication endpoints")
+	}
+}
+
+func TestConfigClient_metadataHost(t *testing.T) {
+	server, resource := newTestMetadataServer(t)
+
+	config := &Config{
+		SubscriptionID:  "00000000-0000-0000-0000-000000000000",
+		ClientID:        "00000000-0000-0000-0000-000000000001",
+		TenantID:        "00000000-0000-0000-0000-000000000002",
+		UseClientSecret: true,
+		ClientSecret:    "secret",
+		MetadataHost:    server.URL,
+	}
+
+	meta, diags := config.Client("terraform-provider-azurepreview")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if v := testAuthorizationHeader(t, meta.Subscription.Authorizer); v != "Bearer stack-token" {
+		t.Fatalf("expected Authorization %q, got %q", "Bearer stack-token", v)
+	}
+
+	if *resource != testTokenAudience {
+		t.Fatalf("expected token to be requested for %q, got %q", testTokenAudience, *resource)
+	}
+
+	expected := server.URL + "/"
+	for name, baseURI := range map[string]string{
+		"Budgets":       meta.Budgets.BaseURI,
+		"Resources":     meta.Resources.BaseURI,
+		"Subscription":  meta.Subscription.BaseURI,
+		"Subscriptions": meta.Subscriptions.BaseURI,
+	} {
+		if baseURI != expected {
+			t.Fatalf("expected %s client to use %q, got %q", name, expected, baseURI)
+		}
+	}
+}
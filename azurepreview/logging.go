@@ -0,0 +1,190 @@
+package azurepreview
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+const redacted = "REDACTED"
+
+// redactedHeaders are never written to the log.
+var redactedHeaders = []string{
+	"Authorization",
+	"x-ms-authorization-auxiliary",
+	"Ocp-Apim-Subscription-Key",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// redactedFields are JSON properties and form fields whose values are never
+// written to the log. Names are matched case-insensitively, and a field is
+// also redacted when its name contains one of these.
+var redactedFields = []string{
+	"password",
+	"secret",
+	"token",
+	"assertion",
+	"key",
+	"connectionstring",
+	"sas",
+}
+
+// withRequestLogging returns a SendDecorator which writes every ARM request
+// to the Terraform log. A summary of each request is logged at DEBUG, and the
+// redacted headers and bodies at TRACE.
+func withRequestLogging() autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			var requestBody []byte
+			if r.Body != nil {
+				requestBody, _ = ioutil.ReadAll(r.Body)
+				r.Body.Close()
+				r.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+			}
+
+			log.Printf("[TRACE] AzurePreview Request: %s %s\n%s%s", r.Method, r.URL, formatHeaders(r.Header), redactBody(r.Header, requestBody))
+
+			start := time.Now()
+			resp, err := s.Do(r)
+			elapsed := time.Since(start).Round(time.Millisecond)
+
+			if err != nil {
+				log.Printf("[DEBUG] AzurePreview HTTP: %s %s failed after %s: %+v", r.Method, r.URL, elapsed, err)
+				return resp, err
+			}
+
+			log.Printf("[DEBUG] AzurePreview HTTP: %s %s -> %d (%s) x-ms-request-id=%q x-ms-correlation-request-id=%q",
+				r.Method, r.URL, resp.StatusCode, elapsed,
+				resp.Header.Get("x-ms-request-id"), resp.Header.Get("x-ms-correlation-request-id"))
+
+			var responseBody []byte
+			if resp.Body != nil {
+				responseBody, _ = ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+			}
+
+			log.Printf("[TRACE] AzurePreview Response: %s %s -> %s\n%s%s", r.Method, r.URL, resp.Status, formatHeaders(resp.Header), redactBody(resp.Header, responseBody))
+
+			return resp, err
+		})
+	}
+}
+
+func formatHeaders(header http.Header) string {
+	header = redactHeaders(header)
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, strings.Join(header[name], ", "))
+	}
+
+	return b.String()
+}
+
+func redactHeaders(header http.Header) http.Header {
+	result := header.Clone()
+
+	for _, name := range redactedHeaders {
+		if result.Get(name) != "" {
+			result.Set(name, redacted)
+		}
+	}
+
+	return result
+}
+
+// redactBody returns a loggable copy of a request or response body. JSON and
+// form bodies are logged with secret-bearing fields redacted; any other body
+// is only described by its length, since it cannot be safely inspected.
+func redactBody(header http.Header, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	contentType := header.Get("Content-Type")
+
+	switch {
+	case strings.Contains(contentType, "application/x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			break
+		}
+
+		for name := range values {
+			if isRedactedField(name) {
+				values.Set(name, redacted)
+			}
+		}
+
+		return values.Encode()
+
+	case strings.Contains(contentType, "json") || json.Valid(body):
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			break
+		}
+
+		result, err := json.Marshal(redactJSON(value))
+		if err != nil {
+			break
+		}
+
+		return string(result)
+	}
+
+	return fmt.Sprintf("(%d bytes of %q not logged)", len(body), contentType)
+}
+
+func redactJSON(input interface{}) interface{} {
+	switch value := input.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if isRedactedField(k) {
+				value[k] = redacted
+				continue
+			}
+
+			value[k] = redactJSON(v)
+		}
+
+		return value
+
+	case []interface{}:
+		for i, v := range value {
+			value[i] = redactJSON(v)
+		}
+
+		return value
+	}
+
+	return input
+}
+
+func isRedactedField(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, field := range redactedFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+
+	return false
+}
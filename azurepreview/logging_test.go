@@ -0,0 +1,157 @@
+package azurepreview
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-11-01/subscriptions"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+func TestRequestLogging_logsRequestsWithoutSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("x-ms-request-id", "11111111-1111-1111-1111-111111111111")
+		w.Header().Set("x-ms-correlation-request-id", "22222222-2222-2222-2222-222222222222")
+		w.Header().Set("Set-Cookie", "session=super-secret-cookie")
+		fmt.Fprintf(w, `{"subscriptionId":"%s","displayName":"example","properties":{"clientSecret":"super-secret-value"}}`, testSubscriptionID)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	client := subscriptions.NewClientWithBaseURI(server.URL + "/")
+	configureClient(&client.Client, &clientOptions{
+		userAgent: "terraform-provider-azurepreview",
+		authorizer: autorest.NewBearerAuthorizer(&adal.Token{
+			AccessToken: "super-secret-token",
+			ExpiresOn:   "0",
+		}),
+		logging: true,
+	})
+
+	if _, err := client.Get(context.Background(), testSubscriptionID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	output := buf.String()
+
+	for _, expected := range []string{
+		fmt.Sprintf("[DEBUG] AzurePreview HTTP: GET %s/subscriptions/%s", server.URL, testSubscriptionID),
+		"-> 200",
+		`x-ms-request-id="11111111-1111-1111-1111-111111111111"`,
+		`x-ms-correlation-request-id="22222222-2222-2222-2222-222222222222"`,
+		`"clientSecret":"REDACTED"`,
+		`"displayName":"example"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected log to contain %q, got:\n%s", expected, output)
+		}
+	}
+
+	if strings.Contains(output, "super-secret") {
+		t.Fatalf("expected secrets to be redacted from the log, got:\n%s", output)
+	}
+}
+
+func TestRequestLogging_disabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"subscriptionId":"%s"}`, testSubscriptionID)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	client := subscriptions.NewClientWithBaseURI(server.URL + "/")
+	configureClient(&client.Client, &clientOptions{
+		authorizer: autorest.NullAuthorizer{},
+	})
+
+	if _, err := client.Get(context.Background(), testSubscriptionID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if strings.Contains(buf.String(), "AzurePreview HTTP") {
+		t.Fatalf("expected no request logging, got:\n%s", buf.String())
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+	header.Set("x-ms-authorization-auxiliary", "Bearer aux1, Bearer aux2")
+	header.Set("Content-Type", "application/json")
+
+	result := redactHeaders(header)
+
+	for _, name := range []string{"Authorization", "x-ms-authorization-auxiliary"} {
+		if v := result.Get(name); v != redacted {
+			t.Fatalf("expected %s to be redacted, got %q", name, v)
+		}
+	}
+
+	if v := result.Get("Content-Type"); v != "application/json" {
+		t.Fatalf("expected Content-Type to be kept, got %q", v)
+	}
+
+	if v := header.Get("Authorization"); v != "Bearer token" {
+		t.Fatalf("expected the original header to be left untouched, got %q", v)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	form := http.Header{}
+	form.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	jsonHeader := http.Header{}
+	jsonHeader.Set("Content-Type", "application/json; charset=utf-8")
+
+	binary := http.Header{}
+	binary.Set("Content-Type", "application/octet-stream")
+
+	cases := []struct {
+		header   http.Header
+		body     string
+		expected string
+	}{
+		{
+			header:   form,
+			body:     "client_id=abc&client_secret=s3cr3t&grant_type=client_credentials",
+			expected: "client_id=abc&client_secret=REDACTED&grant_type=client_credentials",
+		},
+		{
+			header:   jsonHeader,
+			body:     `{"access_token":"abc","expires_in":"3600"}`,
+			expected: `{"access_token":"REDACTED","expires_in":"3600"}`,
+		},
+		{
+			header:   jsonHeader,
+			body:     `{"value":[{"name":"example","properties":{"primaryKey":"abc"}}]}`,
+			expected: `{"value":[{"name":"example","properties":{"primaryKey":"REDACTED"}}]}`,
+		},
+		{
+			header:   binary,
+			body:     "\x00\x01\x02",
+			expected: `(3 bytes of "application/octet-stream" not logged)`,
+		},
+	}
+
+	for _, tc := range cases {
+		if v := redactBody(tc.header, []byte(tc.body)); v != tc.expected {
+			t.Fatalf("expected %q to be logged as %q, got %q", tc.body, tc.expected, v)
+		}
+	}
+}
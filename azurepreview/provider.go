@@ -2,6 +2,10 @@ package azurepreview
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -35,20 +39,204 @@ func Provider() *schema.Provider {
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
+			"use_client_secret": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_USE_CLIENT_SECRET", "ARM_USE_CLIENT_SECRET"}, true),
+			},
+
+			"use_client_certificate": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_USE_CLIENT_CERTIFICATE", "ARM_USE_CLIENT_CERTIFICATE"}, true),
+			},
+
+			"client_certificate_path": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_CLIENT_CERTIFICATE_PATH", "ARM_CLIENT_CERTIFICATE_PATH"}, nil),
+				ConflictsWith:    []string{"client_certificate"},
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"client_certificate": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Sensitive:        true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_CLIENT_CERTIFICATE", "ARM_CLIENT_CERTIFICATE"}, nil),
+				ConflictsWith:    []string{"client_certificate_path"},
+				ValidateDiagFunc: stringIsBase64,
+			},
+
+			"client_certificate_password": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_CLIENT_CERTIFICATE_PASSWORD", "ARM_CLIENT_CERTIFICATE_PASSWORD"}, ""),
+			},
+
 			"tenant_id": {
 				Type:             schema.TypeString,
 				Optional:         true,
 				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_TENANT_ID", "ARM_TENANT_ID"}, nil),
-				RequiredWith:     []string{"client_id", "client_secret"},
+				RequiredWith:     []string{"client_id"},
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
+			"auxiliary_tenant_ids": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 3,
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					ValidateDiagFunc: stringIsUUID,
+				},
+			},
+
+			"use_msi": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_USE_MSI", "ARM_USE_MSI"}, false),
+			},
+
+			"msi_endpoint": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_MSI_ENDPOINT", "ARM_MSI_ENDPOINT"}, ""),
+				ValidateDiagFunc: stringIsURL,
+			},
+
+			"use_cli": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_USE_CLI", "ARM_USE_CLI"}, true),
+			},
+
+			"use_oidc": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_USE_OIDC", "ARM_USE_OIDC"}, false),
+			},
+
+			"oidc_token": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.MultiEnvDefaultFunc([]string{"AZURE_OIDC_TOKEN", "ARM_OIDC_TOKEN"}, ""),
+				ConflictsWith: []string{"oidc_token_file_path"},
+			},
+
+			"oidc_token_file_path": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.MultiEnvDefaultFunc([]string{"AZURE_FEDERATED_TOKEN_FILE", "ARM_OIDC_TOKEN_FILE_PATH"}, ""),
+				ConflictsWith: []string{"oidc_token"},
+			},
+
+			"oidc_request_url": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL", "SYSTEM_OIDCREQUESTURI"}, ""),
+				ValidateDiagFunc: stringIsURL,
+			},
+
+			"oidc_request_token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN", "SYSTEM_ACCESSTOKEN"}, ""),
+			},
+
+			"oidc_azure_service_connection_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{
+					"ARM_OIDC_AZURE_SERVICE_CONNECTION_ID",
+					"ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID",
+					"AZURESUBSCRIPTION_SERVICE_CONNECTION_ID",
+				}, ""),
+			},
+
 			"environment": {
 				Type:             schema.TypeString,
 				Required:         true,
 				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_ENVIRONMENT", "ARM_ENVIRONMENT"}, azure.PublicCloud.Name),
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
+
+			"metadata_host": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_METADATA_HOST", "ARM_METADATA_HOST"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"skip_credentials_validation": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_SKIP_CREDENTIALS_VALIDATION", "ARM_SKIP_CREDENTIALS_VALIDATION"}, false),
+			},
+
+			"proxy_url": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: stringIsURL,
+			},
+
+			"ca_certificate_path": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_CA_CERTIFICATE_PATH", "ARM_CA_CERTIFICATE_PATH"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"request_timeout": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: stringIsDuration,
+			},
+
+			"partner_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"AZURE_PARTNER_ID", "ARM_PARTNER_ID"}, nil),
+				ValidateDiagFunc: stringIsPartnerID,
+			},
+
+			"enable_http_logging": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"AZURE_HTTP_LOGGING", "ARM_HTTP_LOGGING"}, false),
+			},
+
+			"retry": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"max_attempts": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          defaultRetryMaxAttempts,
+							ValidateDiagFunc: intAtLeast(1),
+						},
+
+						"max_backoff": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          defaultRetryMaxBackoff.String(),
+							ValidateDiagFunc: stringIsDuration,
+						},
+
+						"respect_retry_after": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+					},
+				},
+			},
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
@@ -56,8 +244,9 @@ func Provider() *schema.Provider {
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"azurepreview_subscription": resourceAzurePreviewSubscription(),
-			"azurepreview_budget":       resourceAzurePreviewBudget(),
+			"azurepreview_subscription":       resourceAzurePreviewSubscription(),
+			"azurepreview_budget":             resourceAzurePreviewBudget(),
+			"azurepreview_subscription_alias": resourceAzurePreviewSubscriptionAlias(),
 		},
 	}
 
@@ -74,10 +263,83 @@ func providerConfigure(p *schema.Provider) schema.ConfigureContextFunc {
 			ClientSecret:   d.Get("client_secret").(string),
 			TenantID:       d.Get("tenant_id").(string),
 			Environment:    d.Get("environment").(string),
+			MetadataHost:   d.Get("metadata_host").(string),
+
+			AuxiliaryTenantIDs: *expandStringSlice(d.Get("auxiliary_tenant_ids").([]interface{})),
+
+			SkipCredentialsValidation: d.Get("skip_credentials_validation").(bool),
+
+			ProxyURL:          d.Get("proxy_url").(string),
+			CACertificatePath: d.Get("ca_certificate_path").(string),
+
+			UseClientSecret: d.Get("use_client_secret").(bool),
+
+			UseClientCertificate:      d.Get("use_client_certificate").(bool),
+			ClientCertificatePath:     d.Get("client_certificate_path").(string),
+			ClientCertificate:         d.Get("client_certificate").(string),
+			ClientCertificatePassword: d.Get("client_certificate_password").(string),
+
+			UseMSI:      d.Get("use_msi").(bool),
+			MSIEndpoint: d.Get("msi_endpoint").(string),
+
+			UseCLI: d.Get("use_cli").(bool),
+
+			UseOIDC:           d.Get("use_oidc").(bool),
+			OIDCToken:         d.Get("oidc_token").(string),
+			OIDCTokenFilePath: d.Get("oidc_token_file_path").(string),
+			OIDCRequestURL:    d.Get("oidc_request_url").(string),
+			OIDCRequestToken:  d.Get("oidc_request_token").(string),
+
+			OIDCAzureServiceConnectionID: d.Get("oidc_azure_service_connection_id").(string),
+
+			Retry:       expandProviderRetryPolicy(d.Get("retry").([]interface{})),
+			HTTPLogging: d.Get("enable_http_logging").(bool),
+		}
+
+		if v := d.Get("request_timeout").(string); v != "" {
+			config.RequestTimeout, _ = time.ParseDuration(v)
 		}
 
-		ua := p.UserAgent(TerraformProviderUserAgent, p.TerraformVersion)
+		ua := buildUserAgent(p.UserAgent(TerraformProviderUserAgent, p.TerraformVersion), d.Get("partner_id").(string))
 
 		return config.Client(ua)
 	}
 }
+
+func expandProviderRetryPolicy(input []interface{}) RetryPolicy {
+	policy := defaultRetryPolicy()
+
+	if len(input) == 0 || input[0] == nil {
+		return policy
+	}
+
+	values := input[0].(map[string]interface{})
+
+	if v, ok := values["max_attempts"]; ok {
+		policy.MaxAttempts = v.(int)
+	}
+
+	if v, ok := values["max_backoff"]; ok {
+		policy.MaxBackoff, _ = time.ParseDuration(v.(string))
+	}
+
+	if v, ok := values["respect_retry_after"]; ok {
+		policy.RespectRetryAfter = v.(bool)
+	}
+
+	return policy
+}
+
+// buildUserAgent appends the customer usage attribution ID and any fragment
+// set in AZURE_HTTP_USER_AGENT to the provider's user agent.
+func buildUserAgent(userAgent, partnerID string) string {
+	if v := strings.TrimSpace(os.Getenv("AZURE_HTTP_USER_AGENT")); v != "" {
+		userAgent = fmt.Sprintf("%s %s", userAgent, v)
+	}
+
+	if partnerID != "" {
+		userAgent = fmt.Sprintf("%s pid-%s", userAgent, strings.TrimPrefix(partnerID, "pid-"))
+	}
+
+	return userAgent
+}
@@ -2,8 +2,10 @@ package azurepreview
 
 import (
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -36,3 +38,78 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatal("AZURE_TEST_ENROLLMENT_ACCOUNT must be set for acceptance tests")
 	}
 }
+
+func TestBuildUserAgent(t *testing.T) {
+	defer os.Unsetenv("AZURE_HTTP_USER_AGENT")
+
+	base := "Terraform/0.14.0 (+https://www.terraform.io) terraform-provider-azurepreview"
+
+	cases := []struct {
+		env       string
+		partnerID string
+		expected  string
+	}{
+		{
+			expected: base,
+		},
+		{
+			partnerID: "11111111-1111-1111-1111-111111111111",
+			expected:  base + " pid-11111111-1111-1111-1111-111111111111",
+		},
+		{
+			partnerID: "pid-11111111-1111-1111-1111-111111111111",
+			expected:  base + " pid-11111111-1111-1111-1111-111111111111",
+		},
+		{
+			env:      " my-pipeline/1.0 ",
+			expected: base + " my-pipeline/1.0",
+		},
+		{
+			env:       "my-pipeline/1.0",
+			partnerID: "11111111-1111-1111-1111-111111111111",
+			expected:  base + " my-pipeline/1.0 pid-11111111-1111-1111-1111-111111111111",
+		},
+	}
+
+	for _, tc := range cases {
+		os.Setenv("AZURE_HTTP_USER_AGENT", tc.env)
+
+		if v := buildUserAgent(base, tc.partnerID); v != tc.expected {
+			t.Fatalf("expected user agent %q, got %q", tc.expected, v)
+		}
+	}
+}
+
+func TestProvider_partnerID(t *testing.T) {
+	server := newTestARMServer(t)
+
+	raw := testProviderConfig(server)
+	raw["partner_id"] = "11111111-1111-1111-1111-111111111111"
+
+	meta := testProviderMeta(t, raw)
+
+	for name, userAgent := range map[string]string{
+		"Budgets":       meta.Budgets.UserAgent,
+		"Resources":     meta.Resources.UserAgent,
+		"Subscription":  meta.Subscription.UserAgent,
+		"Subscriptions": meta.Subscriptions.UserAgent,
+	} {
+		if !strings.HasSuffix(userAgent, " pid-11111111-1111-1111-1111-111111111111") {
+			t.Fatalf("expected %s client user agent to end with the partner ID, got %q", name, userAgent)
+		}
+	}
+}
+
+func TestStringIsPartnerID(t *testing.T) {
+	for v, valid := range map[string]bool{
+		"11111111-1111-1111-1111-111111111111":     true,
+		"pid-11111111-1111-1111-1111-111111111111": true,
+		"pid-":         false,
+		"not-a-guid":   false,
+		"partner-1234": false,
+	} {
+		if diags := stringIsPartnerID(v, cty.Path{}); diags.HasError() == valid {
+			t.Fatalf("expected %q to be valid: %t, got %+v", v, valid, diags)
+		}
+	}
+}
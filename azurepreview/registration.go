@@ -0,0 +1,143 @@
+package azurepreview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-06-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+)
+
+const resourceProviderRegistrationPollInterval = 10 * time.Second
+
+// withResourceProviderRegistration returns a SendDecorator which registers
+// the resource provider and sends the request again when ARM rejects it with
+// 409 MissingSubscriptionRegistration, which is common for subscriptions that
+// were only just created.
+//
+// It replaces the SDK's azure.DoRetryWithRegistration, which nests a second
+// retry loop inside it, so that the retry policy stays the only one.
+func withResourceProviderRegistration(o *clientOptions) autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			rr := autorest.NewRetriableRequest(r)
+
+			if err := rr.Prepare(); err != nil {
+				return nil, err
+			}
+
+			resp, err := s.Do(rr.Request())
+			if err != nil || resp.StatusCode != http.StatusConflict {
+				return resp, err
+			}
+
+			namespace, ok := missingSubscriptionRegistration(resp)
+			if !ok {
+				return resp, err
+			}
+
+			subscriptionID := subscriptionIDFromPath(r.URL.Path)
+			if subscriptionID == "" {
+				return resp, err
+			}
+
+			baseURI := (&url.URL{Scheme: r.URL.Scheme, Host: r.URL.Host}).String()
+			if err := registerResourceProvider(r.Context(), o, baseURI, subscriptionID, namespace); err != nil {
+				return resp, fmt.Errorf("error registering Resource Provider %q in Subscription %q: %+v", namespace, subscriptionID, err)
+			}
+
+			if err := rr.Prepare(); err != nil {
+				return resp, err
+			}
+
+			autorest.DrainResponseBody(resp)
+
+			return s.Do(rr.Request())
+		})
+	}
+}
+
+// missingSubscriptionRegistration returns the namespace of the resource
+// provider a 409 response asks to be registered. The body is left readable
+// for the caller.
+func missingSubscriptionRegistration(resp *http.Response) (string, bool) {
+	if resp.Body == nil {
+		return "", false
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err != nil {
+		return "", false
+	}
+
+	var payload struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details []struct {
+				Target string `json:"target"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", false
+	}
+
+	if payload.Error.Code != "MissingSubscriptionRegistration" || len(payload.Error.Details) == 0 || payload.Error.Details[0].Target == "" {
+		return "", false
+	}
+
+	return payload.Error.Details[0].Target, true
+}
+
+// subscriptionIDFromPath returns the subscription a request path is scoped
+// to, or an empty string when it is not scoped to one.
+func subscriptionIDFromPath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if strings.EqualFold(segments[i], "subscriptions") {
+			return segments[i+1]
+		}
+	}
+
+	return ""
+}
+
+// registerResourceProvider registers namespace in the subscription and waits
+// until the registration has finished.
+func registerResourceProvider(ctx context.Context, o *clientOptions, baseURI, subscriptionID, namespace string) error {
+	client := resources.NewProvidersClientWithBaseURI(baseURI, subscriptionID)
+	configureClient(&client.Client, o)
+
+	log.Printf("[INFO] Registering Resource Provider %q in Subscription %q", namespace, subscriptionID)
+
+	provider, err := client.Register(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	for provider.RegistrationState == nil || !strings.EqualFold(*provider.RegistrationState, "Registered") {
+		select {
+		case <-time.After(resourceProviderRegistrationPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if provider, err = client.Get(ctx, namespace, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
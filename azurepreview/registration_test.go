@@ -0,0 +1,79 @@
+package azurepreview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/consumption/mgmt/2019-01-01/consumption"
+	"github.com/Azure/go-autorest/autorest"
+)
+
+func TestResourceProviderRegistration_registersAndRetries(t *testing.T) {
+	var lock sync.Mutex
+	registered := false
+	requests := make([]string, 0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Consumption/register", testSubscriptionID):
+			registered = true
+			fmt.Fprint(w, `{"namespace":"Microsoft.Consumption","registrationState":"Registered"}`)
+
+		case fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Consumption/budgets/example", testSubscriptionID):
+			if !registered {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"error":{"code":"MissingSubscriptionRegistration","message":"The subscription is not registered to use namespace 'Microsoft.Consumption'.","details":[{"code":"MissingSubscriptionRegistration","target":"Microsoft.Consumption"}]}}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"name":"example"}`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := consumption.NewBudgetsClientWithBaseURI(server.URL, testSubscriptionID)
+	configureClient(&client.Client, &clientOptions{
+		authorizer: autorest.NullAuthorizer{},
+		retry:      defaultRetryPolicy(),
+	})
+
+	budget, err := client.Get(context.Background(), fmt.Sprintf("subscriptions/%s", testSubscriptionID), "example")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if budget.Name == nil || *budget.Name != "example" {
+		t.Fatalf("expected the budget to be read once the provider was registered, got %+v", budget)
+	}
+
+	if len(requests) != 3 || requests[1] != fmt.Sprintf("POST /subscriptions/%s/providers/Microsoft.Consumption/register", testSubscriptionID) {
+		t.Fatalf("expected the request to be sent again after registering the provider, got %v", requests)
+	}
+}
+
+func TestSubscriptionIDFromPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Consumption/budgets/example": "00000000-0000-0000-0000-000000000000",
+		"/SUBSCRIPTIONS/example":                                         "example",
+		"/providers/Microsoft.Subscription/aliases/example":              "",
+		"/providers/Microsoft.Management/managementGroups/subscriptions": "",
+	} {
+		if v := subscriptionIDFromPath(path); v != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, path, v)
+		}
+	}
+}
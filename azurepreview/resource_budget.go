@@ -2,6 +2,7 @@ package azurepreview
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/consumption/mgmt/2019-01-01/consumption"
@@ -20,11 +21,23 @@ func resourceAzurePreviewBudget() *schema.Resource {
 		UpdateContext: resourceAzurePreviewBudgetCreateUpdate,
 		DeleteContext: resourceAzurePreviewBudgetDelete,
 
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceAzurePreviewBudgetImport,
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"scope": {
 				Type:             schema.TypeString,
 				Required:         true,
 				ForceNew:         true,
+				DiffSuppressFunc: suppressBudgetScopeDiff,
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
@@ -282,6 +295,7 @@ func resourceAzurePreviewBudgetRead(ctx context.Context, d *schema.ResourceData,
 
 	d.Set("scope", id.Scope)
 	d.Set("name", resp.Name)
+	d.Set("category", resp.Category)
 	d.Set("amount", resp.Amount.IntPart())
 	d.Set("time_grain", resp.TimeGrain)
 	d.Set("time_period", flattenAzurePreviewBudgetTimePeriod(resp.TimePeriod))
@@ -311,6 +325,27 @@ func resourceAzurePreviewBudgetDelete(ctx context.Context, d *schema.ResourceDat
 	return diags
 }
 
+// resourceAzurePreviewBudgetImport accepts the resource ID of a budget at any
+// scope supported by parseBudgetID.
+func resourceAzurePreviewBudgetImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	id, err := parseBudgetID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.Set("scope", id.Scope)
+	d.Set("name", id.BudgetName)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// suppressBudgetScopeDiff ignores a leading slash and case differences in the
+// scope, since budget IDs returned by the API do not always match the case
+// used in configuration.
+func suppressBudgetScopeDiff(k, old, new string, d *schema.ResourceData) bool {
+	return strings.EqualFold(strings.TrimPrefix(old, "/"), strings.TrimPrefix(new, "/"))
+}
+
 func expandAzurePreviewBudgetTimePeriod(input []interface{}) *consumption.BudgetTimePeriod {
 	if len(input) == 0 {
 		return nil
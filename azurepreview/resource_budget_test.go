@@ -1,7 +1,9 @@
 package azurepreview
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"testing"
 
@@ -29,6 +31,135 @@ func TestAccAzurePreviewBudget_basic(t *testing.T) {
 	})
 }
 
+func TestParseBudgetID(t *testing.T) {
+	cases := []struct {
+		id    string
+		scope string
+		name  string
+	}{
+		{
+			id:    "/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Consumption/budgets/example",
+			scope: "subscriptions/00000000-0000-0000-0000-000000000000",
+			name:  "example",
+		},
+		{
+			id:    "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/example-rg/providers/Microsoft.Consumption/budgets/example",
+			scope: "subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/example-rg",
+			name:  "example",
+		},
+		{
+			id:    "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/example-rg/providers/microsoft.consumption/budgets/example",
+			scope: "subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/example-rg",
+			name:  "example",
+		},
+		{
+			id:    "/providers/Microsoft.Management/managementGroups/example-mg/providers/Microsoft.Consumption/budgets/example",
+			scope: "providers/Microsoft.Management/managementGroups/example-mg",
+			name:  "example",
+		},
+		{
+			id:    "/providers/Microsoft.Billing/billingAccounts/1234567/providers/Microsoft.Consumption/budgets/example",
+			scope: "providers/Microsoft.Billing/billingAccounts/1234567",
+			name:  "example",
+		},
+		{
+			id:    "/providers/Microsoft.Billing/billingAccounts/1234567/departments/890/providers/Microsoft.Consumption/budgets/example",
+			scope: "providers/Microsoft.Billing/billingAccounts/1234567/departments/890",
+			name:  "example",
+		},
+	}
+
+	for _, tc := range cases {
+		id, err := parseBudgetID(tc.id)
+		if err != nil {
+			t.Fatalf("err parsing %q: %s", tc.id, err)
+		}
+
+		if id.Scope != tc.scope {
+			t.Fatalf("expected scope %q for %q, got %q", tc.scope, tc.id, id.Scope)
+		}
+
+		if id.BudgetName != tc.name {
+			t.Fatalf("expected name %q for %q, got %q", tc.name, tc.id, id.BudgetName)
+		}
+	}
+}
+
+func TestParseBudgetID_invalid(t *testing.T) {
+	for _, id := range []string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Consumption/budgets/",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Consumption/budgets/example/notifications",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/example-rg/providers/Microsoft.Network/virtualNetworks/example/providers/Microsoft.Consumption/budgets/example",
+		"/providers/Microsoft.Consumption/budgets/example",
+	} {
+		if _, err := parseBudgetID(id); err == nil {
+			t.Fatalf("expected an error parsing %q", id)
+		}
+	}
+}
+
+func TestAzurePreviewBudget_import(t *testing.T) {
+	server := newTestARMServer(t)
+
+	budgetID := fmt.Sprintf("/subscriptions/%s/resourceGroups/example-rg/providers/Microsoft.Consumption/budgets/example", testSubscriptionID)
+
+	server.HandleFunc(budgetID, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"%s","name":"example","properties":{"category":"Cost","amount":1000,"timeGrain":"Monthly","timePeriod":{"startDate":"2020-01-01T00:00:00Z","endDate":"2030-01-01T00:00:00Z"}}}`, budgetID)
+	})
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := resourceAzurePreviewBudget().Data(nil)
+	d.SetId(budgetID)
+
+	imported, err := resourceAzurePreviewBudgetImport(context.Background(), d, meta)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d = imported[0]
+	if diags := resourceAzurePreviewBudgetRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if d.Id() == "" {
+		t.Fatal("expected the imported budget to exist")
+	}
+
+	for k, expected := range map[string]string{
+		"scope":      fmt.Sprintf("subscriptions/%s/resourceGroups/example-rg", testSubscriptionID),
+		"name":       "example",
+		"category":   "Cost",
+		"time_grain": "Monthly",
+	} {
+		if v := d.Get(k).(string); v != expected {
+			t.Fatalf("expected %s to be %q, got %q", k, expected, v)
+		}
+	}
+
+	if v := d.Get("amount").(int); v != 1000 {
+		t.Fatalf("expected amount to be 1000, got %d", v)
+	}
+}
+
+func TestSuppressBudgetScopeDiff(t *testing.T) {
+	for _, tc := range []struct {
+		old      string
+		new      string
+		suppress bool
+	}{
+		{"subscriptions/example", "/subscriptions/example", true},
+		{"subscriptions/example/resourcegroups/rg", "subscriptions/example/resourceGroups/rg", true},
+		{"subscriptions/example", "subscriptions/other", false},
+	} {
+		if v := suppressBudgetScopeDiff("scope", tc.old, tc.new, nil); v != tc.suppress {
+			t.Fatalf("expected the diff from %q to %q to be suppressed: %t", tc.old, tc.new, tc.suppress)
+		}
+	}
+}
+
 func testAccCheckAzurePreviewBudgetDestroy(s *terraform.State) error {
 	client := testAccProvider.Meta().(*Meta).Budgets
 	ctx := testAccProvider.Meta().(*Meta).StopContext
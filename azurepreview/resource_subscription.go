@@ -2,9 +2,18 @@ package azurepreview
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/services/authorization/mgmt/2015-07-01/authorization"
 	"github.com/Azure/azure-sdk-for-go/services/preview/subscription/mgmt/2019-10-01-preview/subscription"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-11-01/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-06-01/resources"
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -16,6 +25,17 @@ func resourceAzurePreviewSubscription() *schema.Resource {
 		UpdateContext: resourceAzurePreviewSubscriptionUpdate,
 		DeleteContext: resourceAzurePreviewSubscriptionDelete,
 
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceAzurePreviewSubscriptionImport,
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:             schema.TypeString,
@@ -28,28 +48,46 @@ func resourceAzurePreviewSubscription() *schema.Resource {
 				Type:             schema.TypeString,
 				Required:         true,
 				ForceNew:         true,
+				DiffSuppressFunc: suppressUnrecoveredAfterImport,
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
 			"owners": {
-				Type:     schema.TypeList,
+				Type:     schema.TypeSet,
 				Optional: true,
-				ForceNew: true,
 				Elem: &schema.Schema{
-					Type: schema.TypeString,
+					Type:             schema.TypeString,
+					ValidateDiagFunc: stringIsUUID,
 				},
 			},
 
 			"offer_type": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ExactlyOneOf:     []string{"offer_type", "workload"},
+				DiffSuppressFunc: suppressUnrecoveredAfterImport,
+				// Enrollment accounts can only create subscriptions with the
+				// Enterprise Agreement offers.
 				ValidateDiagFunc: stringInSlice([]string{
 					string(subscription.MSAZR0017P),
 					string(subscription.MSAZR0148P),
 				}),
 			},
 
+			"workload": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ExactlyOneOf:     []string{"offer_type", "workload"},
+				DiffSuppressFunc: suppressWorkloadDiff,
+				ValidateDiagFunc: stringInSlice([]string{
+					subscriptionAliasWorkloadProduction,
+					subscriptionAliasWorkloadDevTest,
+				}),
+			},
+
 			"additional_parameters": {
 				Type:     schema.TypeMap,
 				Optional: true,
@@ -59,15 +97,89 @@ func resourceAzurePreviewSubscription() *schema.Resource {
 				},
 			},
 
+			"management_group_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				DiffSuppressFunc: suppressManagementGroupIDDiff,
+				ValidateDiagFunc: stringIsManagementGroupID,
+			},
+
+			"deletion_behavior": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  subscriptionDeletionBehaviorCancel,
+				ValidateDiagFunc: stringInSlice([]string{
+					subscriptionDeletionBehaviorCancel,
+					subscriptionDeletionBehaviorRemoveFromState,
+				}),
+			},
+
+			"prevent_cancellation_if_resources_exist": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"adopt_existing": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"enabled": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Computed: true,
+			},
+
+			"tags": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"subscription_id": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
 
+			"creation_operation": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
 			"tenant_id": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+
+			"state": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"quota_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"spending_limit": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"location_placement_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"authorization_source": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 		},
 	}
 }
@@ -78,7 +190,7 @@ func resourceAzurePreviewSubscriptionCreate(ctx context.Context, d *schema.Resou
 
 	name := d.Get("name").(string)
 	enrollmentAccount := d.Get("enrollment_account").(string)
-	owners := d.Get("owners").([]interface{})
+	owners := d.Get("owners").(*schema.Set).List()
 
 	adPrincipals := make([]subscription.AdPrincipal, 0)
 	for _, owner := range owners {
@@ -89,36 +201,141 @@ func resourceAzurePreviewSubscriptionCreate(ctx context.Context, d *schema.Resou
 		adPrincipals = append(adPrincipals, adPrincipal)
 	}
 
+	offerType := subscription.OfferType(d.Get("offer_type").(string))
+	if v, ok := d.GetOk("workload"); ok {
+		offerType = subscriptionWorkloadOfferTypes[v.(string)]
+	}
+
 	params := subscription.CreationParameters{
 		DisplayName: &name,
 		Owners:      &adPrincipals,
-		OfferType:   subscription.OfferType(d.Get("offer_type").(string)),
+		OfferType:   offerType,
 	}
 
-	future, err := client.CreateSubscriptionInEnrollmentAccount(ctx, enrollmentAccount, params)
-	if err != nil {
-		return diag.Errorf("error creating Subscription %q in Enrollment Account %q: %+v", name, enrollmentAccount, err)
+	if v, ok := d.GetOk("additional_parameters"); ok {
+		params.AdditionalParameters = v.(map[string]interface{})
 	}
 
-	if err = future.WaitForCompletionRef(ctx, client.Client); err != nil {
-		return diag.Errorf("error waiting for Subscription %q in Enrollment Account %q to finish creating: %+v", name, enrollmentAccount, err)
+	// Steps which fail once the subscription exists are reported as warnings
+	// rather than errors, which would taint the new subscription. Their
+	// attributes are cleared so that the next apply retries them.
+	var failed []string
+
+	// A subscription which an interrupted apply created, but never recorded
+	// in state, is adopted instead of being created a second time. The
+	// subscriptions of an enrollment account cannot be listed, so this is
+	// opt-in: any visible subscription with the same name would match.
+	// Setting enabled as well also adopts a cancelled subscription, which is
+	// enabled again, while it is still within its grace period.
+	reenable := d.Get("enabled").(bool)
+
+	var existingID string
+	if d.Get("adopt_existing").(bool) {
+		existingID, diags = findAzurePreviewSubscriptionByName(ctx, meta, name, offerType, reenable)
+		if diags.HasError() {
+			return diags
+		}
 	}
 
-	resp, err := future.Result(client)
+	if existingID != "" {
+		log.Printf("[WARN] Adopting existing Subscription %q named %q instead of creating a new one", existingID, name)
+		d.SetId(fmt.Sprintf("/subscriptions/%s", existingID))
+
+		if reenable {
+			if enableDiags := enableAzurePreviewSubscription(ctx, meta, existingID, true); enableDiags != nil {
+				diags = append(diags, subscriptionCreatedWarnings(enableDiags, "enabled")...)
+				failed = append(failed, "enabled")
+			}
+		}
+
+		if owners := d.Get("owners").(*schema.Set); owners.Len() > 0 {
+			if ownerDiags := updateAzurePreviewSubscriptionOwners(ctx, meta, existingID, schema.NewSet(schema.HashString, nil), owners); ownerDiags != nil {
+				diags = append(diags, subscriptionCreatedWarnings(ownerDiags, "owners")...)
+				failed = append(failed, "owners")
+			}
+		}
+	} else {
+		future, err := client.CreateSubscriptionInEnrollmentAccount(ctx, enrollmentAccount, params)
+		if err != nil {
+			return diag.Errorf("error creating Subscription %q in Enrollment Account %q: %+v", name, enrollmentAccount, err)
+		}
+
+		if err = future.WaitForCompletionRef(ctx, client.Client); err != nil {
+			// The subscription is still being created, so the operation is
+			// recorded in state to be resumed, rather than lost with the
+			// context.
+			if ctx.Err() != nil && future.PollingURL() != "" {
+				return saveAzurePreviewSubscriptionCreation(ctx, d, future)
+			}
+
+			return diag.Errorf("error waiting for Subscription %q in Enrollment Account %q to finish creating: %+v", name, enrollmentAccount, err)
+		}
+
+		resp, err := future.Result(client)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		d.SetId(*resp.SubscriptionLink)
+	}
+
+	d.Set("offer_type", string(offerType))
+
+	subscriptionID, err := parseSubscriptionID(d.Id())
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	d.SetId(*resp.SubscriptionLink)
+	if v, ok := d.GetOk("management_group_id"); ok {
+		if moveDiags := moveAzurePreviewSubscription(ctx, meta, subscriptionID, v.(string)); moveDiags != nil {
+			diags = append(diags, subscriptionCreatedWarnings(moveDiags, "management_group_id")...)
+			failed = append(failed, "management_group_id")
+		}
+	}
+
+	if v, ok := d.GetOk("tags"); ok {
+		if tagDiags := setAzurePreviewSubscriptionTags(ctx, meta, subscriptionID, v.(map[string]interface{})); tagDiags != nil {
+			diags = append(diags, subscriptionCreatedWarnings(tagDiags, "tags")...)
+			failed = append(failed, "tags")
+		}
+	}
 
 	resourceAzurePreviewSubscriptionRead(ctx, d, meta)
 
+	for _, k := range failed {
+		d.Set(k, nil)
+	}
+
 	return diags
 }
 
+// subscriptionCreatedWarnings turns the errors of a step which failed after
+// the subscription was created into warnings.
+func subscriptionCreatedWarnings(diags diag.Diagnostics, attribute string) diag.Diagnostics {
+	warnings := make(diag.Diagnostics, 0, len(diags))
+
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			d.Severity = diag.Warning
+			d.Detail = strings.TrimSpace(fmt.Sprintf("%s\n\nThe subscription was created, but %s could not be applied to it. The next apply retries it.", d.Detail, attribute))
+		}
+
+		warnings = append(warnings, d)
+	}
+
+	return warnings
+}
+
 func resourceAzurePreviewSubscriptionRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
+	if isPendingSubscriptionID(d.Id()) {
+		diags = resumeAzurePreviewSubscriptionCreation(ctx, d, meta)
+		if diags.HasError() || d.Id() == "" || isPendingSubscriptionID(d.Id()) {
+			return diags
+		}
+	}
+
 	client := meta.(*Meta).Subscriptions
 
 	subscriptionID, err := parseSubscriptionID(d.Id())
@@ -136,9 +353,74 @@ func resourceAzurePreviewSubscriptionRead(ctx context.Context, d *schema.Resourc
 		return diag.Errorf("error reading Subscription (ID %q): %+v", d.Id(), err)
 	}
 
+	// A cancelled subscription is deleted once its grace period is over.
+	if resp.State == subscriptions.Deleted {
+		log.Printf("[INFO] Subscription %q has been deleted; removing from state", subscriptionID)
+		d.SetId("")
+		return nil
+	}
+
 	d.Set("name", resp.DisplayName)
 	d.Set("subscription_id", resp.SubscriptionID)
 	d.Set("tenant_id", resp.TenantID)
+	d.Set("state", string(resp.State))
+	d.Set("enabled", resp.State != subscriptions.Disabled)
+	d.Set("authorization_source", resp.AuthorizationSource)
+
+	if policies := resp.SubscriptionPolicies; policies != nil {
+		d.Set("quota_id", policies.QuotaID)
+		d.Set("spending_limit", string(policies.SpendingLimit))
+		d.Set("location_placement_id", policies.LocationPlacementID)
+	}
+
+	if detail, ok := subscriptionStateWarnings[resp.State]; ok {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Subscription %q is %s", subscriptionID, resp.State),
+			Detail:   detail,
+		})
+	}
+
+	// Tags are only read back once they are managed, so that subscriptions
+	// whose tags are left alone never need access to them.
+	if _, ok := d.GetOk("tags"); ok {
+		tags, err := meta.(*Meta).Tags.GetAtScope(ctx, subscriptionScope(subscriptionID))
+		if err != nil && !tags.IsHTTPStatus(404) {
+			return diag.Errorf("error reading tags of Subscription (ID %q): %+v", d.Id(), err)
+		}
+
+		d.Set("tags", flattenAzurePreviewSubscriptionTags(tags.Properties))
+	}
+
+	if v, ok := d.GetOk("owners"); ok {
+		owners, ownerDiags := getAzurePreviewSubscriptionOwners(ctx, meta, subscriptionID)
+		if ownerDiags != nil {
+			return ownerDiags
+		}
+
+		// Only the owners managed here are tracked, so that owners added
+		// outside of Terraform, such as the principal which created the
+		// subscription, are never removed.
+		managed := make([]interface{}, 0)
+		for _, owner := range v.(*schema.Set).List() {
+			if _, ok := owners[strings.ToLower(owner.(string))]; ok {
+				managed = append(managed, owner)
+			}
+		}
+
+		d.Set("owners", managed)
+	}
+
+	// Looking up the parent needs read access to management groups, so it is
+	// only done for subscriptions whose management group is managed here.
+	if d.Get("management_group_id").(string) != "" {
+		managementGroupID, mgDiags := getAzurePreviewSubscriptionManagementGroup(ctx, meta, subscriptionID)
+		if mgDiags != nil {
+			return mgDiags
+		}
+
+		d.Set("management_group_id", managementGroupID)
+	}
 
 	return diags
 }
@@ -146,6 +428,10 @@ func resourceAzurePreviewSubscriptionRead(ctx context.Context, d *schema.Resourc
 func resourceAzurePreviewSubscriptionUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
+	if diags := resolveAzurePreviewSubscriptionCreation(ctx, d, meta); diags != nil {
+		return diags
+	}
+
 	client := meta.(*Meta).Subscription
 
 	subscriptionID, err := parseSubscriptionID(d.Id())
@@ -153,6 +439,14 @@ func resourceAzurePreviewSubscriptionUpdate(ctx context.Context, d *schema.Resou
 		return diag.FromErr(err)
 	}
 
+	// A cancelled subscription is enabled first, since it cannot be changed
+	// while it is disabled.
+	if d.HasChange("enabled") {
+		if diags := enableAzurePreviewSubscription(ctx, meta, subscriptionID, d.Get("enabled").(bool)); diags != nil {
+			return diags
+		}
+	}
+
 	subscriptionName := subscription.Name{
 		SubscriptionName: to.StringPtr(d.Get("name").(string)),
 	}
@@ -164,6 +458,28 @@ func resourceAzurePreviewSubscriptionUpdate(ctx context.Context, d *schema.Resou
 		}
 	}
 
+	if d.HasChange("owners") {
+		old, new := d.GetChange("owners")
+
+		if diags := updateAzurePreviewSubscriptionOwners(ctx, meta, subscriptionID, old.(*schema.Set), new.(*schema.Set)); diags != nil {
+			return diags
+		}
+	}
+
+	if d.HasChange("management_group_id") {
+		if diags := moveAzurePreviewSubscription(ctx, meta, subscriptionID, d.Get("management_group_id").(string)); diags != nil {
+			return diags
+		}
+	}
+
+	// Removing tags from the configuration clears them, since every tag on
+	// the subscription was managed until then.
+	if d.HasChange("tags") {
+		if diags := setAzurePreviewSubscriptionTags(ctx, meta, subscriptionID, d.Get("tags").(map[string]interface{})); diags != nil {
+			return diags
+		}
+	}
+
 	resourceAzurePreviewSubscriptionRead(ctx, d, meta)
 
 	return diags
@@ -172,6 +488,10 @@ func resourceAzurePreviewSubscriptionUpdate(ctx context.Context, d *schema.Resou
 func resourceAzurePreviewSubscriptionDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
+	if diags := resolveAzurePreviewSubscriptionCreation(ctx, d, meta); diags != nil || d.Id() == "" {
+		return diags
+	}
+
 	client := meta.(*Meta).Subscription
 
 	subscriptionID, err := parseSubscriptionID(d.Id())
@@ -179,6 +499,18 @@ func resourceAzurePreviewSubscriptionDelete(ctx context.Context, d *schema.Resou
 		return diag.FromErr(err)
 	}
 
+	if d.Get("deletion_behavior").(string) == subscriptionDeletionBehaviorRemoveFromState {
+		log.Printf("[INFO] Removing Subscription %q from state without cancelling it", subscriptionID)
+		d.SetId("")
+		return diags
+	}
+
+	if d.Get("prevent_cancellation_if_resources_exist").(bool) {
+		if diags := checkAzurePreviewSubscriptionIsEmpty(ctx, meta, subscriptionID); diags != nil {
+			return diags
+		}
+	}
+
 	_, err = client.Cancel(ctx, subscriptionID)
 	if err != nil {
 		return diag.Errorf("error cancelling Subscription (ID %q): %+v", d.Id(), err)
@@ -188,3 +520,515 @@ func resourceAzurePreviewSubscriptionDelete(ctx context.Context, d *schema.Resou
 
 	return diags
 }
+
+const (
+	subscriptionDeletionBehaviorCancel          = "Cancel"
+	subscriptionDeletionBehaviorRemoveFromState = "RemoveFromState"
+)
+
+// enableAzurePreviewSubscription enables a cancelled subscription again,
+// which Azure allows until it is deleted at the end of its grace period.
+// Subscriptions cannot be disabled other than by cancelling them.
+func enableAzurePreviewSubscription(ctx context.Context, meta interface{}, subscriptionID string, enabled bool) diag.Diagnostics {
+	client := meta.(*Meta).Subscription
+
+	if !enabled {
+		return diag.Errorf("Subscription %q cannot be disabled without cancelling it; destroy it with `deletion_behavior` set to %q instead",
+			subscriptionID, subscriptionDeletionBehaviorCancel)
+	}
+
+	log.Printf("[INFO] Enabling Subscription %q", subscriptionID)
+
+	if _, err := client.Enable(ctx, subscriptionID); err != nil {
+		return diag.Errorf("error enabling Subscription %q: %+v", subscriptionID, err)
+	}
+
+	return nil
+}
+
+// subscriptionResourcesListed is how many of the resources found in a
+// subscription are named when cancelling it is refused.
+const subscriptionResourcesListed = 10
+
+// checkAzurePreviewSubscriptionIsEmpty refuses to cancel a subscription
+// which still contains resources, naming the first of them.
+func checkAzurePreviewSubscriptionIsEmpty(ctx context.Context, meta interface{}, subscriptionID string) diag.Diagnostics {
+	client := meta.(*Meta).ResourcesClient(subscriptionID)
+
+	iter, err := client.ListComplete(ctx, "", "", nil)
+	if err != nil {
+		return diag.Errorf("error listing resources in Subscription %q: %+v", subscriptionID, err)
+	}
+
+	ids := make([]string, 0)
+	count := 0
+
+	for iter.NotDone() {
+		if v := iter.Value(); v.ID != nil {
+			if count < subscriptionResourcesListed {
+				ids = append(ids, *v.ID)
+			}
+
+			count++
+		}
+
+		if err := iter.NextWithContext(ctx); err != nil {
+			return diag.Errorf("error listing resources in Subscription %q: %+v", subscriptionID, err)
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	detail := fmt.Sprintf("Subscription %q still contains %d resources, so it was not cancelled because `prevent_cancellation_if_resources_exist` is set:\n\n%s",
+		subscriptionID, count, strings.Join(ids, "\n"))
+	if count > len(ids) {
+		detail += fmt.Sprintf("\n\nand %d more.", count-len(ids))
+	}
+
+	return diag.Diagnostics{
+		diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Subscription %q is not empty", subscriptionID),
+			Detail:   detail,
+		},
+	}
+}
+
+// isPendingSubscriptionID reports whether id is the polling URL of a
+// creation operation, recorded in place of the subscription's ID by an apply
+// which was interrupted while the subscription was being created.
+func isPendingSubscriptionID(id string) bool {
+	return id != "" && !strings.HasPrefix(id, "/subscriptions/")
+}
+
+// saveAzurePreviewSubscriptionCreation records a creation operation which
+// is still running in state, so that it can be resumed by the next refresh.
+func saveAzurePreviewSubscriptionCreation(ctx context.Context, d *schema.ResourceData, future subscription.CreateSubscriptionInEnrollmentAccountFuture) diag.Diagnostics {
+	name := d.Get("name").(string)
+
+	operation, err := json.Marshal(future)
+	if err != nil {
+		return diag.Errorf("error recording the creation operation of Subscription %q: %+v", name, err)
+	}
+
+	d.SetId(future.PollingURL())
+	d.Set("creation_operation", string(operation))
+
+	// Only a warning is returned: an error would taint the resource, and the
+	// next apply would then cancel the subscription being created and create
+	// another one in its place.
+	resume := "The creation operation has been recorded in the state and is resumed the next time the subscription is refreshed, " +
+		"so a second subscription will not be created. Until then its `subscription_id` is empty, so resources which depend on it " +
+		"should only be applied once a later plan shows it."
+
+	if diags := timeoutDiagnostics(ctx, d, schema.TimeoutCreate, fmt.Sprintf("waiting for Subscription %q to finish creating", name), ctx.Err()); diags != nil {
+		diags[0].Severity = diag.Warning
+		diags[0].Detail = fmt.Sprintf("%s\n\n%s", diags[0].Detail, resume)
+		return diags
+	}
+
+	return diag.Diagnostics{
+		diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Subscription %q is still being created", name),
+			Detail:   fmt.Sprintf("Terraform was interrupted before the subscription finished creating. %s", resume),
+		},
+	}
+}
+
+// resumeAzurePreviewSubscriptionCreation checks once on a recorded creation
+// operation. When it has finished the resource takes the subscription's ID,
+// and when it has failed the resource is removed from state.
+func resumeAzurePreviewSubscriptionCreation(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*Meta).Subscription
+
+	name := d.Get("name").(string)
+
+	var future subscription.CreateSubscriptionInEnrollmentAccountFuture
+	if err := json.Unmarshal([]byte(d.Get("creation_operation").(string)), &future); err != nil {
+		return diag.Errorf("error reading the recorded creation operation of Subscription %q: %+v", name, err)
+	}
+
+	done, err := future.DoneWithContext(ctx, client)
+	if err != nil {
+		if !done {
+			return diag.Errorf("error checking the creation operation of Subscription %q: %+v", name, err)
+		}
+
+		log.Printf("[WARN] Creating Subscription %q failed: %+v; removing from state", name, err)
+		d.SetId("")
+
+		return diag.Diagnostics{
+			diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Creating Subscription %q failed", name),
+				Detail:   fmt.Sprintf("The creation operation recorded by an earlier apply failed, so the subscription will be created again: %+v", err),
+			},
+		}
+	}
+
+	if !done {
+		return diag.Diagnostics{
+			diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Subscription %q is still being created", name),
+				Detail:   "The creation operation recorded by an earlier apply has not finished yet. Its attributes will be known once it has.",
+			},
+		}
+	}
+
+	resp, err := future.Result(client)
+	if err != nil {
+		return diag.Errorf("error reading the result of the creation operation of Subscription %q: %+v", name, err)
+	}
+
+	log.Printf("[INFO] Resumed creation of Subscription %q: %s", name, *resp.SubscriptionLink)
+	d.SetId(*resp.SubscriptionLink)
+	d.Set("creation_operation", "")
+
+	return nil
+}
+
+// resolveAzurePreviewSubscriptionCreation resumes a recorded creation
+// operation before the subscription is changed, and refuses while the
+// subscription is still being created.
+func resolveAzurePreviewSubscriptionCreation(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if !isPendingSubscriptionID(d.Id()) {
+		return nil
+	}
+
+	if diags := resumeAzurePreviewSubscriptionCreation(ctx, d, meta); diags.HasError() {
+		return diags
+	}
+
+	if isPendingSubscriptionID(d.Id()) {
+		return diag.Errorf("Subscription %q is still being created; try again once it has been created", d.Get("name").(string))
+	}
+
+	return nil
+}
+
+// findAzurePreviewSubscriptionByName returns the ID of the active
+// subscription named name with the given offer type, or an empty string when
+// there is none. Cancelled subscriptions are only returned when
+// includeDisabled is set, and deleted ones never are.
+func findAzurePreviewSubscriptionByName(ctx context.Context, meta interface{}, name string, offerType subscription.OfferType, includeDisabled bool) (string, diag.Diagnostics) {
+	client := meta.(*Meta).Subscriptions
+
+	if name == "" {
+		return "", nil
+	}
+
+	iter, err := client.ListComplete(ctx)
+	if err != nil {
+		return "", diag.Errorf("error listing Subscriptions: %+v", err)
+	}
+
+	matches := make([]string, 0)
+
+	for iter.NotDone() {
+		v := iter.Value()
+
+		if v.SubscriptionID != nil && v.DisplayName != nil && *v.DisplayName == name &&
+			(includeDisabled || v.State != subscriptions.Disabled) && v.State != subscriptions.Deleted &&
+			v.SubscriptionPolicies != nil && v.SubscriptionPolicies.QuotaID != nil &&
+			subscriptionOfferTypes[*v.SubscriptionPolicies.QuotaID] == offerType {
+			matches = append(matches, *v.SubscriptionID)
+		}
+
+		if err := iter.NextWithContext(ctx); err != nil {
+			return "", diag.Errorf("error listing Subscriptions: %+v", err)
+		}
+	}
+
+	if len(matches) > 1 {
+		return "", diag.Errorf("found %d Subscriptions named %q (%s), so none of them could be adopted; import the one to manage instead",
+			len(matches), name, strings.Join(matches, ", "))
+	}
+
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	return "", nil
+}
+
+// setAzurePreviewSubscriptionTags replaces every tag on the subscription
+// with tags; an empty map removes them all.
+func setAzurePreviewSubscriptionTags(ctx context.Context, meta interface{}, subscriptionID string, tags map[string]interface{}) diag.Diagnostics {
+	client := meta.(*Meta).Tags
+
+	params := resources.TagsResource{
+		Properties: &resources.Tags{
+			Tags: expandAzurePreviewSubscriptionTags(tags),
+		},
+	}
+
+	if _, err := client.CreateOrUpdateAtScope(ctx, subscriptionScope(subscriptionID), params); err != nil {
+		return diag.Errorf("error setting tags of Subscription %q: %+v", subscriptionID, err)
+	}
+
+	return nil
+}
+
+func expandAzurePreviewSubscriptionTags(input map[string]interface{}) map[string]*string {
+	result := make(map[string]*string, len(input))
+
+	for k, v := range input {
+		result[k] = to.StringPtr(v.(string))
+	}
+
+	return result
+}
+
+func flattenAzurePreviewSubscriptionTags(input *resources.Tags) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	if input == nil {
+		return result
+	}
+
+	for k, v := range input.Tags {
+		if v != nil {
+			result[k] = *v
+		}
+	}
+
+	return result
+}
+
+// ownerRoleDefinitionID is the ID of the built-in Owner role.
+const ownerRoleDefinitionID = "8e3af657-a8ff-443c-a75c-2fe8c4bcb635"
+
+// getAzurePreviewSubscriptionOwners returns the Owner role assignments made
+// directly on the subscription, keyed by lower case principal ID. Owners
+// inherited from a management group are not included.
+func getAzurePreviewSubscriptionOwners(ctx context.Context, meta interface{}, subscriptionID string) (map[string][]string, diag.Diagnostics) {
+	client := meta.(*Meta).RoleAssignments
+
+	scope := subscriptionScope(subscriptionID)
+
+	iter, err := client.ListForScopeComplete(ctx, scope, "atScope()")
+	if err != nil {
+		return nil, diag.Errorf("error listing owners of Subscription %q: %+v", subscriptionID, err)
+	}
+
+	owners := make(map[string][]string)
+
+	for iter.NotDone() {
+		assignment := iter.Value()
+
+		if props := assignment.Properties; props != nil && assignment.Name != nil &&
+			props.Scope != nil && strings.EqualFold(*props.Scope, "/"+scope) &&
+			props.RoleDefinitionID != nil && strings.HasSuffix(strings.ToLower(*props.RoleDefinitionID), "/"+ownerRoleDefinitionID) &&
+			props.PrincipalID != nil {
+			principalID := strings.ToLower(*props.PrincipalID)
+			owners[principalID] = append(owners[principalID], *assignment.Name)
+		}
+
+		if err := iter.NextWithContext(ctx); err != nil {
+			return nil, diag.Errorf("error listing owners of Subscription %q: %+v", subscriptionID, err)
+		}
+	}
+
+	return owners, nil
+}
+
+// updateAzurePreviewSubscriptionOwners assigns the Owner role on the
+// subscription to principals added to owners, and removes it from those
+// taken out of it.
+func updateAzurePreviewSubscriptionOwners(ctx context.Context, meta interface{}, subscriptionID string, old, new *schema.Set) diag.Diagnostics {
+	client := meta.(*Meta).RoleAssignments
+
+	scope := subscriptionScope(subscriptionID)
+
+	owners, diags := getAzurePreviewSubscriptionOwners(ctx, meta, subscriptionID)
+	if diags != nil {
+		return diags
+	}
+
+	for _, v := range new.Difference(old).List() {
+		principalID := v.(string)
+
+		if _, ok := owners[strings.ToLower(principalID)]; ok {
+			continue
+		}
+
+		name, err := uuid.GenerateUUID()
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		params := authorization.RoleAssignmentCreateParameters{
+			Properties: &authorization.RoleAssignmentProperties{
+				RoleDefinitionID: to.StringPtr(fmt.Sprintf("/%s/providers/Microsoft.Authorization/roleDefinitions/%s", scope, ownerRoleDefinitionID)),
+				PrincipalID:      to.StringPtr(principalID),
+			},
+		}
+
+		if _, err := client.Create(ctx, scope, name, params); err != nil {
+			return diag.Errorf("error adding owner %q to Subscription %q: %+v", principalID, subscriptionID, err)
+		}
+	}
+
+	for _, v := range old.Difference(new).List() {
+		principalID := v.(string)
+
+		for _, name := range owners[strings.ToLower(principalID)] {
+			resp, err := client.Delete(ctx, scope, name)
+			if err != nil && !resp.IsHTTPStatus(404) {
+				return diag.Errorf("error removing owner %q from Subscription %q: %+v", principalID, subscriptionID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// moveAzurePreviewSubscription places the subscription in the management
+// group, taking it out of the group it was in before. Subscriptions are
+// never taken out of a group without being moved to another, since
+// management_group_id is computed when it is not configured.
+func moveAzurePreviewSubscription(ctx context.Context, meta interface{}, subscriptionID, managementGroupID string) diag.Diagnostics {
+	client := meta.(*Meta).ManagementGroupSubscriptions
+
+	groupName, err := parseManagementGroupID(managementGroupID)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if _, err := client.Create(ctx, groupName, subscriptionID, "no-cache"); err != nil {
+		return diag.Errorf("error moving Subscription %q to Management Group %q: %+v", subscriptionID, groupName, err)
+	}
+
+	return nil
+}
+
+// getAzurePreviewSubscriptionManagementGroup returns the ID of the management
+// group the subscription is currently in.
+func getAzurePreviewSubscriptionManagementGroup(ctx context.Context, meta interface{}, subscriptionID string) (string, diag.Diagnostics) {
+	client := meta.(*Meta).ManagementGroupEntities
+
+	filter := fmt.Sprintf("name eq '%s'", subscriptionID)
+
+	iter, err := client.ListComplete(ctx, "", "", filter, "", "", "no-cache")
+	if err != nil {
+		return "", diag.Errorf("error reading Management Group of Subscription %q: %+v", subscriptionID, err)
+	}
+
+	for iter.NotDone() {
+		entity := iter.Value()
+
+		if entity.Type != nil && strings.EqualFold(*entity.Type, "/subscriptions") &&
+			entity.Name != nil && strings.EqualFold(*entity.Name, subscriptionID) &&
+			entity.EntityInfoProperties != nil && entity.Parent != nil && entity.Parent.ID != nil {
+			return *entity.Parent.ID, nil
+		}
+
+		if err := iter.NextWithContext(ctx); err != nil {
+			return "", diag.Errorf("error reading Management Group of Subscription %q: %+v", subscriptionID, err)
+		}
+	}
+
+	return "", nil
+}
+
+func suppressManagementGroupIDDiff(k, old, new string, d *schema.ResourceData) bool {
+	return strings.EqualFold(old, new)
+}
+
+// subscriptionStateWarnings explain the subscription states which are
+// reported as warnings, since deployments into them are likely to fail.
+var subscriptionStateWarnings = map[subscriptions.State]string{
+	subscriptions.Disabled: "The subscription has been disabled, either because it was cancelled or because its spending limit was reached. Resources in it cannot be created or changed until it is enabled again, which setting `enabled` to true does for a cancelled subscription.",
+	subscriptions.Warned:   "The subscription has a billing problem, such as an overdue payment, and will be disabled if it is not resolved.",
+	subscriptions.PastDue:  "Payment for the subscription is past due, and it will be disabled if the balance is not paid.",
+}
+
+// subscriptionWorkloadOfferTypes maps a workload to the Enterprise Agreement
+// offer type for it.
+var subscriptionWorkloadOfferTypes = map[string]subscription.OfferType{
+	subscriptionAliasWorkloadProduction: subscription.MSAZR0017P,
+	subscriptionAliasWorkloadDevTest:    subscription.MSAZR0148P,
+}
+
+// subscriptionOfferTypes maps the quota ID in a subscription's policies to
+// the offer type it was created with. Only Enterprise Agreement offers can be
+// created by this resource, so other quota IDs are not mapped.
+var subscriptionOfferTypes = map[string]subscription.OfferType{
+	"EnterpriseAgreement_2014-09-01": subscription.MSAZR0017P,
+	"MSDNDevTest_2014-09-01":         subscription.MSAZR0148P,
+}
+
+// resourceAzurePreviewSubscriptionImport accepts either a subscription ID or
+// its resource ID. The enrollment account a subscription was created in
+// cannot be read back from Resource Manager, so it is left unset, as is
+// offer_type when the subscription's quota ID is not a known offer.
+func resourceAzurePreviewSubscriptionImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*Meta).Subscriptions
+
+	subscriptionID := d.Id()
+	if strings.HasPrefix(subscriptionID, "/") {
+		v, err := parseSubscriptionID(subscriptionID)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptionID = v
+	}
+
+	if _, err := uuid.ParseUUID(subscriptionID); err != nil {
+		return nil, fmt.Errorf("error importing Subscription: expected a subscription ID or /subscriptions/<subscription ID>, got %q", d.Id())
+	}
+
+	resp, err := client.Get(ctx, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("error importing Subscription %q: %+v", subscriptionID, err)
+	}
+
+	d.SetId(fmt.Sprintf("/subscriptions/%s", subscriptionID))
+
+	if policies := resp.SubscriptionPolicies; policies != nil && policies.QuotaID != nil {
+		if offerType, ok := subscriptionOfferTypes[*policies.QuotaID]; ok {
+			d.Set("offer_type", string(offerType))
+		} else {
+			log.Printf("[WARN] Subscription %q has quota ID %q, which does not map to an offer type; leaving `offer_type` unset", subscriptionID, *policies.QuotaID)
+		}
+	}
+
+	log.Printf("[WARN] The enrollment account of Subscription %q cannot be recovered; leaving `enrollment_account` unset", subscriptionID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// suppressUnrecoveredAfterImport keeps an imported subscription from being
+// replaced because of an argument that import could not recover. Imported
+// subscriptions are the only ones without an enrollment_account in state,
+// since it is required when creating one.
+func suppressUnrecoveredAfterImport(k, old, new string, d *schema.ResourceData) bool {
+	if d.Id() == "" || old != "" {
+		return false
+	}
+
+	enrollmentAccount, _ := d.GetChange("enrollment_account")
+
+	return enrollmentAccount.(string) == ""
+}
+
+// suppressWorkloadDiff ignores setting a workload which selects the offer
+// type the subscription already has, for example when switching from
+// offer_type to workload. Any other new workload replaces the subscription.
+func suppressWorkloadDiff(k, old, new string, d *schema.ResourceData) bool {
+	if d.Id() == "" || old != "" {
+		return false
+	}
+
+	offerType, _ := d.GetChange("offer_type")
+	if offerType.(string) == "" {
+		return suppressUnrecoveredAfterImport(k, old, new, d)
+	}
+
+	return string(subscriptionWorkloadOfferTypes[new]) == offerType.(string)
+}
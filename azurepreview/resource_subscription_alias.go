@@ -0,0 +1,266 @@
+package azurepreview
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceAzurePreviewSubscriptionAlias() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceAzurePreviewSubscriptionAliasCreate,
+		ReadContext:   resourceAzurePreviewSubscriptionAliasRead,
+		DeleteContext: resourceAzurePreviewSubscriptionAliasDelete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"display_name": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringLengthBetween(1, 64),
+			},
+
+			"billing_scope_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ExactlyOneOf:     []string{"billing_scope_id", "subscription_id"},
+				ValidateDiagFunc: stringIsBillingScopeID,
+			},
+
+			"subscription_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ExactlyOneOf:     []string{"billing_scope_id", "subscription_id"},
+				ValidateDiagFunc: stringIsUUID,
+			},
+
+			"workload": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Default:  subscriptionAliasWorkloadProduction,
+				ValidateDiagFunc: stringInSlice([]string{
+					subscriptionAliasWorkloadProduction,
+					subscriptionAliasWorkloadDevTest,
+				}),
+			},
+
+			"reseller_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"management_group_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsManagementGroupID,
+				DiffSuppressFunc: suppressManagementGroupIDDiff,
+			},
+
+			"subscription_owner_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsUUID,
+			},
+		},
+	}
+}
+
+const (
+	subscriptionAliasWorkloadProduction = "Production"
+	subscriptionAliasWorkloadDevTest    = "DevTest"
+)
+
+func resourceAzurePreviewSubscriptionAliasCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*Meta).SubscriptionAliases
+
+	name := d.Get("name").(string)
+
+	props := SubscriptionAliasRequestProperties{
+		Workload: to.StringPtr(d.Get("workload").(string)),
+	}
+
+	if v, ok := d.GetOk("display_name"); ok {
+		props.DisplayName = to.StringPtr(v.(string))
+	}
+
+	if v, ok := d.GetOk("billing_scope_id"); ok {
+		props.BillingScope = to.StringPtr(v.(string))
+	}
+
+	if v, ok := d.GetOk("subscription_id"); ok {
+		props.SubscriptionID = to.StringPtr(v.(string))
+	}
+
+	if v, ok := d.GetOk("reseller_id"); ok {
+		props.ResellerID = to.StringPtr(v.(string))
+	}
+
+	additional := SubscriptionAliasAdditionalProperties{}
+
+	if v, ok := d.GetOk("management_group_id"); ok {
+		additional.ManagementGroupID = to.StringPtr(v.(string))
+		props.AdditionalProperties = &additional
+	}
+
+	if v, ok := d.GetOk("subscription_owner_id"); ok {
+		additional.SubscriptionOwnerID = to.StringPtr(v.(string))
+		props.AdditionalProperties = &additional
+	}
+
+	params := SubscriptionAliasRequest{
+		Properties: &props,
+	}
+
+	if _, err := client.Create(ctx, name, params); err != nil {
+		return diag.Errorf("error creating Subscription Alias %q: %+v", name, err)
+	}
+
+	if err := waitForSubscriptionAlias(ctx, client, name, d.Timeout(schema.TimeoutCreate)); err != nil {
+		if diags := timeoutDiagnostics(ctx, d, schema.TimeoutCreate, fmt.Sprintf("waiting for Subscription Alias %q to finish provisioning", name), err); diags != nil {
+			return diags
+		}
+
+		return diag.Errorf("error waiting for Subscription Alias %q to finish provisioning: %+v", name, err)
+	}
+
+	d.SetId(fmt.Sprintf("/providers/Microsoft.Subscription/aliases/%s", name))
+
+	return resourceAzurePreviewSubscriptionAliasRead(ctx, d, meta)
+}
+
+func resourceAzurePreviewSubscriptionAliasRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := meta.(*Meta).SubscriptionAliases
+
+	name, err := parseSubscriptionAliasID(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	resp, err := client.Get(ctx, name)
+	if err != nil {
+		if resp.IsHTTPStatus(404) {
+			d.SetId("")
+			return nil
+		}
+
+		return diag.Errorf("error reading Subscription Alias (ID %q): %+v", d.Id(), err)
+	}
+
+	d.Set("name", resp.Name)
+
+	// The aliases API only returns the properties it knows about, so values
+	// missing from the response are left as configured.
+	if props := resp.Properties; props != nil {
+		d.Set("subscription_id", props.SubscriptionID)
+
+		if props.DisplayName != nil {
+			d.Set("display_name", props.DisplayName)
+		}
+
+		if props.BillingScope != nil {
+			d.Set("billing_scope_id", props.BillingScope)
+		}
+
+		if props.Workload != nil {
+			d.Set("workload", props.Workload)
+		}
+
+		if props.ResellerID != nil {
+			d.Set("reseller_id", props.ResellerID)
+		}
+
+		if props.ManagementGroupID != nil {
+			d.Set("management_group_id", props.ManagementGroupID)
+		}
+
+		if props.SubscriptionOwnerID != nil {
+			d.Set("subscription_owner_id", props.SubscriptionOwnerID)
+		}
+	}
+
+	return diags
+}
+
+func resourceAzurePreviewSubscriptionAliasDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := meta.(*Meta).SubscriptionAliases
+
+	name, err := parseSubscriptionAliasID(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	resp, err := client.Delete(ctx, name)
+	if err != nil && !resp.IsHTTPStatus(404) {
+		return diag.Errorf("error deleting Subscription Alias (ID %q): %+v", d.Id(), err)
+	}
+
+	d.SetId("")
+
+	return diags
+}
+
+// waitForSubscriptionAlias polls an alias until its subscription has been
+// provisioned, or provisioning has failed.
+func waitForSubscriptionAlias(ctx context.Context, client SubscriptionAliasesClient, name string, timeout time.Duration) error {
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{"Accepted", "Running"},
+		Target:  []string{"Succeeded"},
+		Timeout: timeout,
+		Refresh: func() (interface{}, string, error) {
+			resp, err := client.Get(ctx, name)
+			if err != nil {
+				if resp.IsHTTPStatus(404) {
+					return nil, "", nil
+				}
+
+				return nil, "", err
+			}
+
+			state := ""
+			if resp.Properties != nil && resp.Properties.ProvisioningState != nil {
+				state = *resp.Properties.ProvisioningState
+			}
+
+			if state == "Failed" {
+				return resp, state, fmt.Errorf("provisioning of the subscription failed")
+			}
+
+			return resp, state, nil
+		},
+	}
+
+	_, err := stateConf.WaitForStateContext(ctx)
+
+	return err
+}
@@ -0,0 +1,247 @@
+package azurepreview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const testBillingScopeID = "/providers/Microsoft.Billing/billingAccounts/1234567/enrollmentAccounts/7654321"
+
+// testSubscriptionAliasServer adds a fake aliases API to a testARMServer.
+// Aliases report Accepted until they have been read pollsUntilDone times.
+type testSubscriptionAliasServer struct {
+	lock    sync.Mutex
+	body    map[string]interface{}
+	polls   int
+	deleted bool
+}
+
+func newTestSubscriptionAliasServer(server *testARMServer, pollsUntilDone int) *testSubscriptionAliasServer {
+	aliases := &testSubscriptionAliasServer{}
+
+	server.HandleFunc("/providers/Microsoft.Subscription/aliases/example", func(w http.ResponseWriter, r *http.Request) {
+		aliases.lock.Lock()
+		defer aliases.lock.Unlock()
+
+		if v := r.URL.Query().Get("api-version"); v != subscriptionAliasAPIVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&aliases.body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":"/providers/Microsoft.Subscription/aliases/example","name":"example","properties":{"provisioningState":"Accepted"}}`)
+
+		case http.MethodGet:
+			if aliases.body == nil || aliases.deleted {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":{"code":"NotFound","message":"not found"}}`)
+				return
+			}
+
+			state := "Succeeded"
+			if aliases.polls < pollsUntilDone {
+				aliases.polls++
+				state = "Accepted"
+			}
+
+			fmt.Fprintf(w, `{"id":"/providers/Microsoft.Subscription/aliases/example","name":"example","properties":{"subscriptionId":"%s","displayName":"Example","provisioningState":"%s","billingScope":"%s","workload":"DevTest"}}`,
+				testSubscriptionID, state, testBillingScopeID)
+
+		case http.MethodDelete:
+			aliases.deleted = true
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	return aliases
+}
+
+func TestAzurePreviewSubscriptionAlias_createPollsUntilProvisioned(t *testing.T) {
+	server := newTestARMServer(t)
+	aliases := newTestSubscriptionAliasServer(server, 2)
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscriptionAlias().Schema, map[string]interface{}{
+		"name":                  "example",
+		"display_name":          "Example",
+		"billing_scope_id":      testBillingScopeID,
+		"workload":              "DevTest",
+		"management_group_id":   "/providers/Microsoft.Management/managementGroups/example-group",
+		"subscription_owner_id": "00000000-0000-0000-0000-000000000004",
+	})
+
+	if diags := resourceAzurePreviewSubscriptionAliasCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if d.Id() != "/providers/Microsoft.Subscription/aliases/example" {
+		t.Fatalf("unexpected ID %q", d.Id())
+	}
+
+	if aliases.polls != 2 {
+		t.Fatalf("expected the alias to be polled until provisioned, got %d Accepted polls", aliases.polls)
+	}
+
+	if v := d.Get("subscription_id").(string); v != testSubscriptionID {
+		t.Fatalf("expected subscription_id %q, got %q", testSubscriptionID, v)
+	}
+
+	props := aliases.body["properties"].(map[string]interface{})
+	for k, expected := range map[string]string{
+		"displayName":  "Example",
+		"billingScope": testBillingScopeID,
+		"workload":     "DevTest",
+	} {
+		if v := props[k]; v != expected {
+			t.Fatalf("expected %s to be %q, got %v", k, expected, v)
+		}
+	}
+
+	additional := props["additionalProperties"].(map[string]interface{})
+	if v := additional["managementGroupId"]; v != "/providers/Microsoft.Management/managementGroups/example-group" {
+		t.Fatalf("expected managementGroupId %q, got %v", "/providers/Microsoft.Management/managementGroups/example-group", v)
+	}
+
+	if v := additional["subscriptionOwnerId"]; v != "00000000-0000-0000-0000-000000000004" {
+		t.Fatalf("expected subscriptionOwnerId %q, got %v", "00000000-0000-0000-0000-000000000004", v)
+	}
+
+	if diags := resourceAzurePreviewSubscriptionAliasDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if !aliases.deleted {
+		t.Fatal("expected the alias to be deleted")
+	}
+}
+
+func TestStringIsBillingScopeID(t *testing.T) {
+	for v, valid := range map[string]bool{
+		"/providers/Microsoft.Billing/billingAccounts/1234567/enrollmentAccounts/7654321":                                  true,
+		"/providers/Microsoft.Billing/billingAccounts/1234:5678_2019-05-31/billingProfiles/ABCD/invoiceSections/EFGH":      true,
+		"/providers/Microsoft.Billing/billingAccounts/1234:5678_2019-05-31/customers/00000000-0000-0000-0000-000000000005": true,
+		"/providers/Microsoft.Billing/billingAccounts/1234567":                                                             false,
+		"/providers/Microsoft.Billing/billingAccounts/1234567/billingProfiles/ABCD":                                        false,
+		"7654321": false,
+	} {
+		if diags := stringIsBillingScopeID(v, cty.Path{}); diags.HasError() == valid {
+			t.Fatalf("expected %q to be valid: %t, got %+v", v, valid, diags)
+		}
+	}
+}
+
+func TestAccAzurePreviewSubscriptionAlias_basic(t *testing.T) {
+	name := fmt.Sprintf("testacc-%s", acctest.RandString(6))
+	billingScopeID := os.Getenv("AZURE_TEST_BILLING_SCOPE_ID")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() {
+			testAccPreCheck(t)
+
+			if billingScopeID == "" {
+				t.Fatal("AZURE_TEST_BILLING_SCOPE_ID must be set for acceptance tests")
+			}
+		},
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckAzurePreviewSubscriptionAliasDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckAzurePreviewSubscriptionAliasConfigBasic(name, billingScopeID),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("azurepreview_subscription_alias.test", "subscription_id"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckAzurePreviewSubscriptionAliasDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*Meta).SubscriptionAliases
+	ctx := testAccProvider.Meta().(*Meta).StopContext
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "azurepreview_subscription_alias" {
+			continue
+		}
+
+		name, err := parseSubscriptionAliasID(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Get(ctx, name)
+		if err != nil {
+			if resp.IsHTTPStatus(404) {
+				return nil
+			}
+
+			return err
+		}
+
+		return fmt.Errorf("Subscription Alias still exists: %s", rs.Primary.ID)
+	}
+
+	return nil
+}
+
+func testAccCheckAzurePreviewSubscriptionAliasConfigBasic(name, billingScopeID string) string {
+	return fmt.Sprintf(`
+resource "azurepreview_subscription_alias" "test" {
+  name             = "%[1]s"
+  display_name     = "%[1]s"
+  billing_scope_id = "%[2]s"
+  workload         = "DevTest"
+}
+`, name, billingScopeID)
+}
+
+func TestAzurePreviewSubscriptionAlias_createTimeout(t *testing.T) {
+	server := newTestARMServer(t)
+	newTestSubscriptionAliasServer(server, 1000)
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscriptionAlias().Schema, map[string]interface{}{
+		"name":             "example",
+		"billing_scope_id": testBillingScopeID,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	diags := resourceAzurePreviewSubscriptionAliasCreate(ctx, d, meta)
+	if !diags.HasError() {
+		t.Fatal("expected creating the alias to time out")
+	}
+
+	if !strings.HasPrefix(diags[0].Summary, "Timed out waiting for Subscription Alias") {
+		t.Fatalf("expected a timeout diagnostic, got %+v", diags)
+	}
+
+	if d.Id() != "" {
+		t.Fatalf("expected no ID after a timeout, got %q", d.Id())
+	}
+}
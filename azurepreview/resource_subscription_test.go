@@ -1,12 +1,22 @@
 package azurepreview
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
@@ -30,6 +40,106 @@ func TestAccAzurePreviewSubscription_basic(t *testing.T) {
 	})
 }
 
+func TestAzurePreviewSubscription_import(t *testing.T) {
+	server := newTestARMServer(t)
+
+	meta := testProviderMetaForServer(t, server)
+
+	for _, importID := range []string{testSubscriptionID, fmt.Sprintf("/subscriptions/%s", testSubscriptionID)} {
+		d := resourceAzurePreviewSubscription().Data(nil)
+		d.SetId(importID)
+
+		imported, err := resourceAzurePreviewSubscriptionImport(context.Background(), d, meta)
+		if err != nil {
+			t.Fatalf("err importing %q: %s", importID, err)
+		}
+
+		d = imported[0]
+		if expected := fmt.Sprintf("/subscriptions/%s", testSubscriptionID); d.Id() != expected {
+			t.Fatalf("expected ID %q after importing %q, got %q", expected, importID, d.Id())
+		}
+
+		if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("err: %+v", diags)
+		}
+
+		for k, expected := range map[string]string{
+			"name":            "example",
+			"subscription_id": testSubscriptionID,
+			"tenant_id":       "00000000-0000-0000-0000-000000000002",
+			"offer_type":      "MS-AZR-0017P",
+		} {
+			if v := d.Get(k).(string); v != expected {
+				t.Fatalf("expected %s to be %q after importing %q, got %q", k, expected, importID, v)
+			}
+		}
+
+		// The enrollment account cannot be read back from Resource Manager.
+		if v := d.Get("enrollment_account").(string); v != "" {
+			t.Fatalf("expected enrollment_account to be left unset, got %q", v)
+		}
+	}
+}
+
+func TestAzurePreviewSubscription_importInvalidID(t *testing.T) {
+	for _, importID := range []string{"example", "/subscriptions/example", "/subscriptions"} {
+		d := resourceAzurePreviewSubscription().Data(nil)
+		d.SetId(importID)
+
+		if _, err := resourceAzurePreviewSubscriptionImport(context.Background(), d, &Meta{}); err == nil {
+			t.Fatalf("expected an error importing %q", importID)
+		}
+	}
+}
+
+func TestSuppressUnrecoveredAfterImport(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{})
+
+	if suppressUnrecoveredAfterImport("enrollment_account", "", "example", d) {
+		t.Fatal("expected a new subscription not to suppress enrollment_account")
+	}
+
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	if !suppressUnrecoveredAfterImport("enrollment_account", "", "example", d) {
+		t.Fatal("expected an imported subscription to suppress its unrecovered enrollment_account")
+	}
+
+	if suppressUnrecoveredAfterImport("enrollment_account", "other", "example", d) {
+		t.Fatal("expected a changed enrollment_account not to be suppressed")
+	}
+}
+
+func TestAzurePreviewSubscription_workloadChange(t *testing.T) {
+	r := resourceAzurePreviewSubscription()
+
+	// A subscription created by Terraform, not imported, with the offer type
+	// of the Production workload.
+	created := r.TestResourceData()
+	created.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+	created.Set("enrollment_account", "example")
+	created.Set("offer_type", "MS-AZR-0017P")
+
+	state := created.State()
+
+	for workload, requiresNew := range map[string]bool{
+		"Production": false,
+		"DevTest":    true,
+	} {
+		diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"enrollment_account": "example",
+			"workload":           workload,
+		}), nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if v := diff != nil && diff.RequiresNew(); v != requiresNew {
+			t.Fatalf("expected switching to the %s workload to require a new subscription to be %t, got %t", workload, requiresNew, v)
+		}
+	}
+}
+
 func testAccCheckAzurePreviewSubscriptionDestroy(s *terraform.State) error {
 	client := testAccProvider.Meta().(*Meta).Subscriptions
 	ctx := testAccProvider.Meta().(*Meta).StopContext
@@ -97,3 +207,855 @@ resource "azurepreview_subscription" "test" {
 }
 `, name, enrollmentAccount, offerType)
 }
+
+func TestAzurePreviewSubscription_tags(t *testing.T) {
+	server := newTestARMServer(t)
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"tags": map[string]interface{}{
+			"cost-center": "1234",
+			"environment": "production",
+		},
+	})
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	if diags := resourceAzurePreviewSubscriptionUpdate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if v := server.tags["cost-center"]; v != "1234" {
+		t.Fatalf("expected the cost-center tag to be set on the subscription, got %v", server.tags)
+	}
+
+	// Tags changed outside of Terraform are picked up by Read.
+	server.tags = map[string]interface{}{
+		"cost-center": "5678",
+	}
+
+	if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	tags := d.Get("tags").(map[string]interface{})
+	if len(tags) != 1 || tags["cost-center"] != "5678" {
+		t.Fatalf("expected tags to be read back from the subscription, got %v", tags)
+	}
+
+	// Removing tags from the configuration clears them.
+	r := resourceAzurePreviewSubscription()
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+	})
+
+	state := d.State()
+
+	diff, err := r.Diff(context.Background(), state, config, meta)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d, err = schema.InternalMap(r.Schema).Data(state, diff)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if diags := resourceAzurePreviewSubscriptionUpdate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if len(server.tags) != 0 {
+		t.Fatalf("expected the tags to be cleared, got %v", server.tags)
+	}
+}
+
+func TestAzurePreviewSubscription_tagsUnmanaged(t *testing.T) {
+	server := newTestARMServer(t)
+	server.tags = map[string]interface{}{
+		"cost-center": "1234",
+	}
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{})
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	// Tags which are not managed are neither read nor planned for removal.
+	for _, r := range server.Requests() {
+		if strings.HasSuffix(r.URL.Path, "/tags/default") {
+			t.Fatalf("expected unmanaged tags not to be read, got %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	if tags := d.Get("tags").(map[string]interface{}); len(tags) != 0 {
+		t.Fatalf("expected no tags in state, got %v", tags)
+	}
+}
+
+// testManagementGroupServer adds a fake management groups API to a
+// testARMServer, holding the management group of the test subscription.
+type testManagementGroupServer struct {
+	lock   sync.Mutex
+	parent string
+}
+
+func newTestManagementGroupServer(server *testARMServer, parent string) *testManagementGroupServer {
+	groups := &testManagementGroupServer{parent: parent}
+
+	server.HandleFunc("/providers/Microsoft.Management/managementGroups/", func(w http.ResponseWriter, r *http.Request) {
+		groups.lock.Lock()
+		defer groups.lock.Unlock()
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/providers/Microsoft.Management/managementGroups/"), "/")
+		if r.Method != http.MethodPut || len(parts) != 3 || parts[1] != "subscriptions" || parts[2] != testSubscriptionID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		groups.parent = parts[0]
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"/providers/Microsoft.Management/managementGroups/%[1]s/subscriptions/%[2]s","name":"%[2]s","properties":{"parent":{"id":"/providers/Microsoft.Management/managementGroups/%[1]s"}}}`,
+			groups.parent, testSubscriptionID)
+	})
+
+	server.HandleFunc("/providers/Microsoft.Management/getEntities", func(w http.ResponseWriter, r *http.Request) {
+		groups.lock.Lock()
+		defer groups.lock.Unlock()
+
+		if r.URL.Query().Get("$filter") != fmt.Sprintf("name eq '%s'", testSubscriptionID) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"value":[{"id":"/subscriptions/%[1]s","type":"/subscriptions","name":"%[1]s","properties":{"parent":{"id":"/providers/Microsoft.Management/managementGroups/%[2]s"}}}]}`,
+			testSubscriptionID, groups.parent)
+	})
+
+	return groups
+}
+
+func TestAzurePreviewSubscription_managementGroup(t *testing.T) {
+	server := newTestARMServer(t)
+	groups := newTestManagementGroupServer(server, "root")
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"management_group_id": "/providers/Microsoft.Management/managementGroups/example",
+	})
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	if diags := resourceAzurePreviewSubscriptionUpdate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if groups.parent != "example" {
+		t.Fatalf("expected the subscription to be moved to management group %q, got %q", "example", groups.parent)
+	}
+
+	// Moves made outside of Terraform are picked up by Read.
+	groups.parent = "other"
+
+	if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if v, expected := d.Get("management_group_id").(string), "/providers/Microsoft.Management/managementGroups/other"; v != expected {
+		t.Fatalf("expected management_group_id %q, got %q", expected, v)
+	}
+}
+
+func TestParseManagementGroupID(t *testing.T) {
+	for input, expected := range map[string]string{
+		"/providers/Microsoft.Management/managementGroups/example":   "example",
+		"/providers/microsoft.management/managementgroups/example":   "example",
+		"/providers/Microsoft.Management/managementGroups/":          "",
+		"/providers/Microsoft.Management/managementGroups/example/x": "",
+		"example": "",
+	} {
+		name, err := parseManagementGroupID(input)
+		if expected == "" {
+			if err == nil {
+				t.Fatalf("expected an error parsing %q, got %q", input, name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("err parsing %q: %s", input, err)
+		}
+
+		if name != expected {
+			t.Fatalf("expected %q to parse to %q, got %q", input, expected, name)
+		}
+	}
+}
+
+func TestAzurePreviewSubscription_deleteProtection(t *testing.T) {
+	server := newTestARMServer(t)
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"prevent_cancellation_if_resources_exist": true,
+	})
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	diags := resourceAzurePreviewSubscriptionDelete(context.Background(), d, meta)
+	if !diags.HasError() {
+		t.Fatal("expected cancelling a subscription with resources to be refused")
+	}
+
+	if !strings.Contains(diags[0].Detail, "/resourceGroups/example/providers/Microsoft.Network/virtualNetworks/example") {
+		t.Fatalf("expected the diagnostic to name the resources in the subscription, got %q", diags[0].Detail)
+	}
+
+	for _, r := range server.Requests() {
+		if strings.HasSuffix(r.URL.Path, "/cancel") {
+			t.Fatal("expected the subscription not to be cancelled")
+		}
+	}
+
+	if d.Id() == "" {
+		t.Fatal("expected the subscription to be kept in state")
+	}
+}
+
+func TestAzurePreviewSubscription_deleteRemoveFromState(t *testing.T) {
+	server := newTestARMServer(t)
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"deletion_behavior": "RemoveFromState",
+	})
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	if diags := resourceAzurePreviewSubscriptionDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if d.Id() != "" {
+		t.Fatalf("expected the subscription to be removed from state, got %q", d.Id())
+	}
+
+	if n := len(server.Requests()); n != 0 {
+		t.Fatalf("expected no requests to Resource Manager, got %d", n)
+	}
+}
+
+func TestAzurePreviewSubscription_readState(t *testing.T) {
+	server := newTestARMServer(t)
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{})
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta)
+	if len(diags) != 0 {
+		t.Fatalf("expected no diagnostics for an enabled subscription, got %+v", diags)
+	}
+
+	for k, expected := range map[string]string{
+		"state":                 "Enabled",
+		"quota_id":              "EnterpriseAgreement_2014-09-01",
+		"spending_limit":        "Off",
+		"location_placement_id": "Public_2014-09-01",
+		"authorization_source":  "RoleBased",
+	} {
+		if v := d.Get(k).(string); v != expected {
+			t.Fatalf("expected %s to be %q, got %q", k, expected, v)
+		}
+	}
+
+	server.state = "PastDue"
+
+	diags = resourceAzurePreviewSubscriptionRead(context.Background(), d, meta)
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("expected a warning for a subscription which is past due, got %+v", diags)
+	}
+
+	server.state = "Deleted"
+
+	if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if d.Id() != "" {
+		t.Fatalf("expected a deleted subscription to be removed from state, got %q", d.Id())
+	}
+}
+
+// testRoleAssignmentServer adds a fake role assignments API for the test
+// subscription to a testARMServer, holding the principal of each assignment
+// by name.
+type testRoleAssignmentServer struct {
+	lock        sync.Mutex
+	assignments map[string]string
+}
+
+func newTestRoleAssignmentServer(server *testARMServer, assignments map[string]string) *testRoleAssignmentServer {
+	roles := &testRoleAssignmentServer{assignments: assignments}
+
+	scope := fmt.Sprintf("/subscriptions/%s", testSubscriptionID)
+	path := scope + "/providers/Microsoft.Authorization/roleAssignments"
+	roleDefinitionID := fmt.Sprintf("%s/providers/Microsoft.Authorization/roleDefinitions/%s", scope, ownerRoleDefinitionID)
+
+	assignment := func(name, principalID string) map[string]interface{} {
+		return map[string]interface{}{
+			"id":   fmt.Sprintf("%s/%s", path, name),
+			"name": name,
+			"properties": map[string]interface{}{
+				"scope":            scope,
+				"roleDefinitionId": roleDefinitionID,
+				"principalId":      principalID,
+			},
+		}
+	}
+
+	server.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		roles.lock.Lock()
+		defer roles.lock.Unlock()
+
+		value := make([]interface{}, 0)
+		for name, principalID := range roles.assignments {
+			value = append(value, assignment(name, principalID))
+		}
+
+		// An assignment inherited from a management group is not an owner
+		// of the subscription itself.
+		value = append(value, map[string]interface{}{
+			"id":   "/providers/Microsoft.Management/managementGroups/example/providers/Microsoft.Authorization/roleAssignments/inherited",
+			"name": "inherited",
+			"properties": map[string]interface{}{
+				"scope":            "/providers/Microsoft.Management/managementGroups/example",
+				"roleDefinitionId": roleDefinitionID,
+				"principalId":      "00000000-0000-0000-0000-000000000009",
+			},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": value})
+	})
+
+	server.HandleFunc(path+"/", func(w http.ResponseWriter, r *http.Request) {
+		roles.lock.Lock()
+		defer roles.lock.Unlock()
+
+		name := strings.TrimPrefix(r.URL.Path, path+"/")
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodPut:
+			var body struct {
+				Properties struct {
+					RoleDefinitionID string `json:"roleDefinitionId"`
+					PrincipalID      string `json:"principalId"`
+				} `json:"properties"`
+			}
+
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Properties.RoleDefinitionID != roleDefinitionID {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			roles.assignments[name] = body.Properties.PrincipalID
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(assignment(name, body.Properties.PrincipalID))
+
+		case http.MethodDelete:
+			principalID, ok := roles.assignments[name]
+			if !ok {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			delete(roles.assignments, name)
+			json.NewEncoder(w).Encode(assignment(name, principalID))
+		}
+	})
+
+	return roles
+}
+
+func (s *testRoleAssignmentServer) Owners() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	owners := make([]string, 0)
+	for _, principalID := range s.assignments {
+		owners = append(owners, principalID)
+	}
+
+	sort.Strings(owners)
+
+	return owners
+}
+
+func TestAzurePreviewSubscription_updateOwners(t *testing.T) {
+	server := newTestARMServer(t)
+	roles := newTestRoleAssignmentServer(server, map[string]string{
+		"creator":  "00000000-0000-0000-0000-000000000001",
+		"previous": "00000000-0000-0000-0000-000000000005",
+	})
+
+	meta := testProviderMetaForServer(t, server)
+
+	r := resourceAzurePreviewSubscription()
+
+	previous := r.TestResourceData()
+	previous.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+	previous.Set("owners", []interface{}{"00000000-0000-0000-0000-000000000005"})
+
+	state := previous.State()
+
+	// The previous owner is swapped for a new one, which must not replace
+	// the subscription.
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+		"owners":             []interface{}{"00000000-0000-0000-0000-000000000006"},
+	})
+
+	diff, err := r.Diff(context.Background(), state, config, meta)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if diff.RequiresNew() {
+		t.Fatal("expected a change to owners not to replace the subscription")
+	}
+
+	d, err := schema.InternalMap(r.Schema).Data(state, diff)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if diags := resourceAzurePreviewSubscriptionUpdate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	// Owners added outside of Terraform are left alone.
+	expected := []string{
+		"00000000-0000-0000-0000-000000000001",
+		"00000000-0000-0000-0000-000000000006",
+	}
+
+	if owners := roles.Owners(); !reflect.DeepEqual(owners, expected) {
+		t.Fatalf("expected owners %v, got %v", expected, owners)
+	}
+
+	if owners := d.Get("owners").(*schema.Set).List(); len(owners) != 1 || owners[0] != "00000000-0000-0000-0000-000000000006" {
+		t.Fatalf("expected only the configured owner in state, got %v", owners)
+	}
+}
+
+// testEnrollmentAccountServer adds a fake createSubscription endpoint for the
+// enrollment account named example to a testARMServer, and records the
+// request bodies it receives. Asynchronous creations are polled until done
+// is set.
+type testEnrollmentAccountServer struct {
+	lock   sync.Mutex
+	bodies []map[string]interface{}
+	done   bool
+}
+
+func newTestEnrollmentAccountServer(server *testARMServer, async bool) *testEnrollmentAccountServer {
+	accounts := &testEnrollmentAccountServer{done: !async}
+
+	operationURL := server.URL + "/providers/Microsoft.Subscription/subscriptionOperations/example"
+
+	respond := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !accounts.done {
+			w.Header().Set("Location", operationURL)
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		fmt.Fprintf(w, `{"subscriptionLink":"/subscriptions/%s"}`, testSubscriptionID)
+	}
+
+	server.HandleFunc("/providers/Microsoft.Billing/enrollmentAccounts/example/providers/Microsoft.Subscription/createSubscription", func(w http.ResponseWriter, r *http.Request) {
+		accounts.lock.Lock()
+		defer accounts.lock.Unlock()
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		accounts.bodies = append(accounts.bodies, body)
+
+		respond(w)
+	})
+
+	server.HandleFunc("/providers/Microsoft.Subscription/subscriptionOperations/example", func(w http.ResponseWriter, r *http.Request) {
+		accounts.lock.Lock()
+		defer accounts.lock.Unlock()
+
+		respond(w)
+	})
+
+	return accounts
+}
+
+func TestAzurePreviewSubscription_createRequestBody(t *testing.T) {
+	server := newTestARMServer(t)
+	accounts := newTestEnrollmentAccountServer(server, false)
+
+	meta := testProviderMetaForServer(t, server)
+
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"name":               "example",
+		"enrollment_account": "example",
+		"workload":           "DevTest",
+		"additional_parameters": map[string]interface{}{
+			"costCenter": "1234",
+		},
+	})
+
+	if diags := resourceAzurePreviewSubscriptionCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if expected := fmt.Sprintf("/subscriptions/%s", testSubscriptionID); d.Id() != expected {
+		t.Fatalf("expected ID %q, got %q", expected, d.Id())
+	}
+
+	if v := d.Get("offer_type").(string); v != "MS-AZR-0148P" {
+		t.Fatalf("expected the DevTest workload to set offer_type %q, got %q", "MS-AZR-0148P", v)
+	}
+
+	if len(accounts.bodies) != 1 {
+		t.Fatalf("expected 1 request to create the subscription, got %d", len(accounts.bodies))
+	}
+
+	expected := map[string]interface{}{
+		"displayName": "example",
+		"offerType":   "MS-AZR-0148P",
+		"owners":      []interface{}{},
+		"additionalParameters": map[string]interface{}{
+			"costCenter": "1234",
+		},
+	}
+
+	if body := accounts.bodies[0]; !reflect.DeepEqual(body, expected) {
+		t.Fatalf("expected request body %v, got %v", expected, body)
+	}
+}
+
+func TestAzurePreviewSubscription_offerTypeValidation(t *testing.T) {
+	r := resourceAzurePreviewSubscription()
+
+	for _, raw := range []map[string]interface{}{
+		{"enrollment_account": "example", "offer_type": "MS-AZR-0017P"},
+		{"enrollment_account": "example", "offer_type": "MS-AZR-0148P"},
+		{"enrollment_account": "example", "workload": "Production"},
+	} {
+		if diags := r.Validate(terraform.NewResourceConfigRaw(raw)); diags.HasError() {
+			t.Fatalf("expected %v to be valid, got %+v", raw, diags)
+		}
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{"enrollment_account": "example", "offer_type": "MS-AZR-0003P"},
+		{"enrollment_account": "example", "offer_type": "MS-AZR-0017P", "workload": "DevTest"},
+		{"enrollment_account": "example"},
+	} {
+		if diags := r.Validate(terraform.NewResourceConfigRaw(raw)); !diags.HasError() {
+			t.Fatalf("expected %v to be invalid", raw)
+		}
+	}
+}
+
+func TestAzurePreviewSubscription_createAdoptsExisting(t *testing.T) {
+	server := newTestARMServer(t)
+	accounts := newTestEnrollmentAccountServer(server, false)
+
+	meta := testProviderMetaForServer(t, server)
+
+	// The fake subscription named example is an active Enterprise Agreement
+	// subscription, as an interrupted apply would have left it.
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"name":               "example",
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+		"adopt_existing":     true,
+	})
+
+	if diags := resourceAzurePreviewSubscriptionCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if expected := fmt.Sprintf("/subscriptions/%s", testSubscriptionID); d.Id() != expected {
+		t.Fatalf("expected the existing subscription %q to be adopted, got %q", expected, d.Id())
+	}
+
+	if len(accounts.bodies) != 0 {
+		t.Fatalf("expected no subscription to be created, got %d requests", len(accounts.bodies))
+	}
+
+	// Without adopt_existing, a subscription with the same name is left
+	// alone, since it may belong to another enrollment account.
+	d = schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"name":               "example",
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+	})
+
+	if diags := resourceAzurePreviewSubscriptionCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if len(accounts.bodies) != 1 {
+		t.Fatalf("expected a new subscription to be created, got %d requests", len(accounts.bodies))
+	}
+
+	// A cancelled subscription with the same name is not adopted.
+	server.state = "Disabled"
+
+	d = schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"name":               "example",
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+		"adopt_existing":     true,
+	})
+
+	if diags := resourceAzurePreviewSubscriptionCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if len(accounts.bodies) != 2 {
+		t.Fatalf("expected a new subscription to be created, got %d requests", len(accounts.bodies))
+	}
+}
+
+func TestAzurePreviewSubscription_createResumesInterrupted(t *testing.T) {
+	server := newTestARMServer(t)
+	accounts := newTestEnrollmentAccountServer(server, true)
+
+	meta := testProviderMetaForServer(t, server)
+
+	r := resourceAzurePreviewSubscription()
+	raw := map[string]interface{}{
+		"name":               "interrupted",
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+	}
+
+	d := schema.TestResourceDataRaw(t, r.Schema, raw)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	// The interrupted apply only warns, so that the resource is not tainted,
+	// and the operation is kept to be resumed.
+	diags := resourceAzurePreviewSubscriptionCreate(ctx, d, meta)
+	if len(diags) != 1 || diags.HasError() || !strings.HasPrefix(diags[0].Summary, "Timed out") {
+		t.Fatalf("expected a timeout warning, got %+v", diags)
+	}
+
+	if !isPendingSubscriptionID(d.Id()) || d.Get("creation_operation").(string) == "" {
+		t.Fatalf("expected the creation operation to be recorded, got ID %q", d.Id())
+	}
+
+	// The next apply refreshes the subscription while it is still being
+	// created, and plans no replacement.
+	if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); diags.HasError() || !isPendingSubscriptionID(d.Id()) {
+		t.Fatalf("expected the subscription to still be being created, got ID %q and %+v", d.Id(), diags)
+	}
+
+	testCheckAzurePreviewSubscriptionNotReplaced(t, r, d, raw)
+
+	// Destroying it cannot cancel it until it exists.
+	if diags := resourceAzurePreviewSubscriptionDelete(context.Background(), d, meta); !diags.HasError() || !isPendingSubscriptionID(d.Id()) {
+		t.Fatalf("expected destroying a subscription being created to fail, got ID %q and %+v", d.Id(), diags)
+	}
+
+	accounts.lock.Lock()
+	accounts.done = true
+	accounts.lock.Unlock()
+
+	// Once the creation has finished, the next apply's refresh resumes it.
+	if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %+v", diags)
+	}
+
+	if expected := fmt.Sprintf("/subscriptions/%s", testSubscriptionID); d.Id() != expected {
+		t.Fatalf("expected ID %q once the creation finished, got %q", expected, d.Id())
+	}
+
+	if v := d.Get("subscription_id").(string); v != testSubscriptionID {
+		t.Fatalf("expected subscription_id %q, got %q", testSubscriptionID, v)
+	}
+
+	testCheckAzurePreviewSubscriptionNotReplaced(t, r, d, raw)
+
+	if len(accounts.bodies) != 1 {
+		t.Fatalf("expected the subscription to be created once, got %d requests", len(accounts.bodies))
+	}
+}
+
+// testCheckAzurePreviewSubscriptionNotReplaced plans raw against the state of
+// d and fails when the plan replaces the subscription.
+func testCheckAzurePreviewSubscriptionNotReplaced(t *testing.T, r *schema.Resource, d *schema.ResourceData, raw map[string]interface{}) {
+	state := d.State()
+	if state.Tainted {
+		t.Fatalf("expected the subscription not to be tainted")
+	}
+
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if diff != nil && (diff.RequiresNew() || diff.Destroy) {
+		t.Fatalf("expected the subscription not to be replaced, got %+v", diff)
+	}
+}
+
+func TestAzurePreviewSubscription_createStepFailure(t *testing.T) {
+	server := newTestARMServer(t)
+	accounts := newTestEnrollmentAccountServer(server, false)
+
+	meta := testProviderMetaForServer(t, server)
+
+	// The fake server has no management groups API, so the subscription is
+	// created but cannot be moved.
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"name":                "new",
+		"enrollment_account":  "example",
+		"offer_type":          "MS-AZR-0017P",
+		"management_group_id": "/providers/Microsoft.Management/managementGroups/example",
+	})
+
+	diags := resourceAzurePreviewSubscriptionCreate(context.Background(), d, meta)
+	if diags.HasError() {
+		t.Fatalf("expected a failed move not to fail the creation, got %+v", diags)
+	}
+
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("expected a warning about the failed move, got %+v", diags)
+	}
+
+	if len(accounts.bodies) != 1 {
+		t.Fatalf("expected a subscription to be created, got %d requests", len(accounts.bodies))
+	}
+
+	if expected := fmt.Sprintf("/subscriptions/%s", testSubscriptionID); d.Id() != expected {
+		t.Fatalf("expected ID %q, got %q", expected, d.Id())
+	}
+
+	// Clearing management_group_id makes the next plan retry the move.
+	if v := d.Get("management_group_id").(string); v != "" {
+		t.Fatalf("expected management_group_id to be cleared, got %q", v)
+	}
+}
+
+func TestAzurePreviewSubscription_enable(t *testing.T) {
+	server := newTestARMServer(t)
+	server.state = "Disabled"
+
+	meta := testProviderMetaForServer(t, server)
+
+	r := resourceAzurePreviewSubscription()
+
+	d := r.TestResourceData()
+	d.SetId(fmt.Sprintf("/subscriptions/%s", testSubscriptionID))
+
+	if diags := resourceAzurePreviewSubscriptionRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if d.Get("enabled").(bool) {
+		t.Fatal("expected a cancelled subscription not to be enabled")
+	}
+
+	// Setting enabled re-enables the cancelled subscription in place.
+	state := d.State()
+
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+		"enabled":            true,
+	}), meta)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if diff.RequiresNew() {
+		t.Fatal("expected enabling the subscription not to replace it")
+	}
+
+	d, err = schema.InternalMap(r.Schema).Data(state, diff)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if diags := resourceAzurePreviewSubscriptionUpdate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if server.state != "Enabled" || !d.Get("enabled").(bool) {
+		t.Fatalf("expected the subscription to be enabled, got state %q", server.state)
+	}
+
+	// Subscriptions can only be disabled by cancelling them.
+	if diags := enableAzurePreviewSubscription(context.Background(), meta, testSubscriptionID, false); !diags.HasError() {
+		t.Fatal("expected disabling the subscription to fail")
+	}
+}
+
+func TestAzurePreviewSubscription_createAdoptsCancelled(t *testing.T) {
+	server := newTestARMServer(t)
+	server.state = "Disabled"
+	accounts := newTestEnrollmentAccountServer(server, false)
+
+	meta := testProviderMetaForServer(t, server)
+
+	// A subscription cancelled by an earlier destroy is adopted and enabled
+	// again while it is still within its grace period.
+	d := schema.TestResourceDataRaw(t, resourceAzurePreviewSubscription().Schema, map[string]interface{}{
+		"name":               "example",
+		"enrollment_account": "example",
+		"offer_type":         "MS-AZR-0017P",
+		"adopt_existing":     true,
+		"enabled":            true,
+	})
+
+	if diags := resourceAzurePreviewSubscriptionCreate(context.Background(), d, meta); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %+v", diags)
+	}
+
+	if expected := fmt.Sprintf("/subscriptions/%s", testSubscriptionID); d.Id() != expected {
+		t.Fatalf("expected the cancelled subscription %q to be adopted, got %q", expected, d.Id())
+	}
+
+	if len(accounts.bodies) != 0 {
+		t.Fatalf("expected no subscription to be created, got %d requests", len(accounts.bodies))
+	}
+
+	if server.state != "Enabled" || !d.Get("enabled").(bool) {
+		t.Fatalf("expected the adopted subscription to be enabled, got state %q", server.state)
+	}
+}
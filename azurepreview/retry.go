@@ -0,0 +1,138 @@
+package azurepreview
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// RetryPolicy controls how ARM requests which were throttled or failed with a
+// transient error are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+
+	// MaxBackoff caps the exponential backoff between attempts.
+	MaxBackoff time.Duration
+
+	// RespectRetryAfter waits for the duration of a Retry-After header
+	// instead of the computed backoff, when the response carries one.
+	RespectRetryAfter bool
+}
+
+const (
+	defaultRetryMaxAttempts = 5
+	defaultRetryMaxBackoff  = 60 * time.Second
+	retryInitialBackoff     = 1 * time.Second
+)
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       defaultRetryMaxAttempts,
+		MaxBackoff:        defaultRetryMaxBackoff,
+		RespectRetryAfter: true,
+	}
+}
+
+var retryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// withRetryPolicy returns a SendDecorator which retries throttled (429) and
+// transient (408, 5xx) responses as well as connection errors. Failures to
+// authenticate are returned straight away.
+func withRetryPolicy(policy RetryPolicy) autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(r *http.Request) (resp *http.Response, err error) {
+			rr := autorest.NewRetriableRequest(r)
+
+			for attempt := 1; ; attempt++ {
+				if err = rr.Prepare(); err != nil {
+					return resp, err
+				}
+
+				autorest.DrainResponseBody(resp)
+
+				resp, err = s.Do(rr.Request())
+				if err == nil && !autorest.ResponseHasStatusCode(resp, retryStatusCodes...) || isAuthenticationError(err) {
+					return resp, err
+				}
+
+				if attempt >= policy.MaxAttempts {
+					return resp, err
+				}
+
+				delay := policy.backoff(attempt, resp)
+
+				if err != nil {
+					log.Printf("[DEBUG] %s %s failed (attempt %d of %d), retrying in %s: %+v", r.Method, r.URL, attempt, policy.MaxAttempts, delay, err)
+				} else {
+					log.Printf("[DEBUG] %s %s returned %d (attempt %d of %d), retrying in %s", r.Method, r.URL, resp.StatusCode, attempt, policy.MaxAttempts, delay)
+				}
+
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+					return resp, r.Context().Err()
+				}
+			}
+		})
+	}
+}
+
+// backoff returns how long to wait before the next attempt. The Retry-After
+// header takes precedence when it is respected, otherwise the delay doubles
+// with every attempt up to MaxBackoff.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if p.RespectRetryAfter {
+		if delay, ok := retryAfter(resp); ok {
+			return delay
+		}
+	}
+
+	delay := retryInitialBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	return delay
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if delay := time.Until(t); delay > 0 {
+			return delay, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,273 @@
+package azurepreview
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/subscription/mgmt/2019-10-01-preview/subscription"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-11-01/subscriptions"
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// newTestThrottlingServer returns a fake ARM endpoint which replies with the
+// given status codes, in order, before answering successfully.
+func newTestThrottlingServer(t *testing.T, retryAfter string, statusCodes ...int) (*httptest.Server, *int32) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if n <= len(statusCodes) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statusCodes[n-1])
+			fmt.Fprint(w, `{"error":{"code":"TooManyRequests","message":"throttled"}}`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"subscriptionId":"%s","displayName":"example"}`, testSubscriptionID)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func testRetryClient(baseURI string, policy RetryPolicy) subscriptions.Client {
+	client := subscriptions.NewClientWithBaseURI(baseURI + "/")
+	configureClient(&client.Client, &clientOptions{
+		userAgent:  "terraform-provider-azurepreview",
+		authorizer: autorest.NullAuthorizer{},
+		retry:      policy,
+	})
+	return client
+}
+
+func TestRetryPolicy_retriesThrottlingAndServerErrors(t *testing.T) {
+	server, requests := newTestThrottlingServer(t, "",
+		http.StatusTooManyRequests,
+		http.StatusServiceUnavailable,
+		http.StatusBadGateway)
+
+	client := testRetryClient(server.URL, RetryPolicy{
+		MaxAttempts: 5,
+		MaxBackoff:  10 * time.Millisecond,
+	})
+
+	resp, err := client.Get(context.Background(), testSubscriptionID)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if v := *resp.DisplayName; v != "example" {
+		t.Fatalf("expected display name %q, got %q", "example", v)
+	}
+
+	if n := atomic.LoadInt32(requests); n != 4 {
+		t.Fatalf("expected 4 requests, got %d", n)
+	}
+}
+
+func TestRetryPolicy_stopsAfterMaxAttempts(t *testing.T) {
+	server, requests := newTestThrottlingServer(t, "",
+		http.StatusTooManyRequests,
+		http.StatusTooManyRequests,
+		http.StatusTooManyRequests,
+		http.StatusTooManyRequests)
+
+	client := testRetryClient(server.URL, RetryPolicy{
+		MaxAttempts: 3,
+		MaxBackoff:  10 * time.Millisecond,
+	})
+
+	resp, err := client.Get(context.Background(), testSubscriptionID)
+	if err == nil {
+		t.Fatal("expected an error once all attempts were throttled")
+	}
+
+	if !resp.IsHTTPStatus(http.StatusTooManyRequests) {
+		t.Fatalf("expected the last throttled response to be returned, got %d", resp.StatusCode)
+	}
+
+	if n := atomic.LoadInt32(requests); n != 3 {
+		t.Fatalf("expected 3 requests, got %d", n)
+	}
+}
+
+func TestRetryPolicy_doesNotRetryClientErrors(t *testing.T) {
+	server, requests := newTestThrottlingServer(t, "", http.StatusNotFound)
+
+	client := testRetryClient(server.URL, RetryPolicy{
+		MaxAttempts: 3,
+		MaxBackoff:  10 * time.Millisecond,
+	})
+
+	if _, err := client.Get(context.Background(), testSubscriptionID); err == nil {
+		t.Fatal("expected an error for a 404 response")
+	}
+
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Fatalf("expected 1 request, got %d", n)
+	}
+}
+
+func TestRetryPolicy_respectsRetryAfter(t *testing.T) {
+	server, requests := newTestThrottlingServer(t, "1", http.StatusTooManyRequests)
+
+	client := testRetryClient(server.URL, RetryPolicy{
+		MaxAttempts:       2,
+		MaxBackoff:        10 * time.Millisecond,
+		RespectRetryAfter: true,
+	})
+
+	start := time.Now()
+
+	if _, err := client.Get(context.Background(), testSubscriptionID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected to wait for the Retry-After header, waited %s", elapsed)
+	}
+
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Fatalf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxBackoff: 5 * time.Second,
+	}
+
+	for attempt, expected := range map[int]time.Duration{
+		1: 1 * time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if v := policy.backoff(attempt, nil); v != expected {
+			t.Fatalf("expected backoff for attempt %d to be %s, got %s", attempt, expected, v)
+		}
+	}
+}
+
+func TestConfigureClient_pollingRetryDuration(t *testing.T) {
+	// The SDK doubles RetryDuration after every failed poll without a cap,
+	// so it must not start at max_backoff.
+	client := testRetryClient("https://management.azure.com", defaultRetryPolicy())
+
+	if client.RetryDuration != retryInitialBackoff {
+		t.Fatalf("expected RetryDuration %s, got %s", retryInitialBackoff, client.RetryDuration)
+	}
+
+	if client.RetryAttempts != defaultRetryMaxAttempts {
+		t.Fatalf("expected RetryAttempts %d, got %d", defaultRetryMaxAttempts, client.RetryAttempts)
+	}
+}
+
+func TestRetryPolicy_retriesPolling(t *testing.T) {
+	var polls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/providers/Microsoft.Billing/enrollmentAccounts/example/providers/Microsoft.Subscription/createSubscription":
+			w.Header().Set("Location", fmt.Sprintf("http://%s/providers/Microsoft.Subscription/subscriptionOperations/example", r.Host))
+			w.WriteHeader(http.StatusAccepted)
+
+		case "/providers/Microsoft.Subscription/subscriptionOperations/example":
+			// The first poll fails with a transient error, which the retry
+			// policy handles rather than the SDK's polling loop.
+			if atomic.AddInt32(&polls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			fmt.Fprintf(w, `{"subscriptionLink":"/subscriptions/%s"}`, testSubscriptionID)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	client := subscription.NewClientWithBaseURI(server.URL)
+	configureClient(&client.Client, &clientOptions{
+		authorizer: autorest.NullAuthorizer{},
+		retry: RetryPolicy{
+			MaxAttempts: 3,
+			MaxBackoff:  10 * time.Millisecond,
+		},
+		logging: true,
+	})
+	client.PollingDelay = 10 * time.Millisecond
+
+	ctx := context.Background()
+
+	future, err := client.CreateSubscriptionInEnrollmentAccount(ctx, "example", subscription.CreationParameters{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := future.WaitForCompletionRef(ctx, client.Client); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if n := atomic.LoadInt32(&polls); n != 2 {
+		t.Fatalf("expected 2 polls, got %d", n)
+	}
+
+	output := buf.String()
+
+	for _, expected := range []string{
+		fmt.Sprintf("GET %s/providers/Microsoft.Subscription/subscriptionOperations/example returned 503 (attempt 1 of 3)", server.URL),
+		fmt.Sprintf("[DEBUG] AzurePreview HTTP: GET %s/providers/Microsoft.Subscription/subscriptionOperations/example", server.URL),
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected log to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestRetryPolicy_doesNotRetryAuthenticationErrors(t *testing.T) {
+	attempts := 0
+
+	sender := autorest.DecorateSender(autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, autorest.NewErrorWithError(&authenticationError{message: "invalid client secret"}, "autorest/Client", "Do", nil, "Preparing request failed")
+	}), withRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		MaxBackoff:  10 * time.Millisecond,
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "https://management.azure.com/subscriptions", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := sender.Do(req); !isAuthenticationError(err) {
+		t.Fatalf("expected an authentication error, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,167 @@
+package azurepreview
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// subscriptionAliasAPIVersion is the first version of the aliases API which
+// accepts a management group and subscription owner; the SDK only ships the
+// 2020-09-01 version, so the client is written against the REST API here.
+const subscriptionAliasAPIVersion = "2021-10-01"
+
+// SubscriptionAliasesClient manages Microsoft.Subscription/aliases, which
+// create new subscriptions or give existing ones a tenant-wide alias.
+type SubscriptionAliasesClient struct {
+	autorest.Client
+	BaseURI string
+}
+
+func NewSubscriptionAliasesClientWithBaseURI(baseURI string) SubscriptionAliasesClient {
+	return SubscriptionAliasesClient{
+		Client:  autorest.NewClientWithUserAgent(TerraformProviderUserAgent),
+		BaseURI: baseURI,
+	}
+}
+
+type SubscriptionAliasRequest struct {
+	Properties *SubscriptionAliasRequestProperties `json:"properties,omitempty"`
+}
+
+type SubscriptionAliasRequestProperties struct {
+	DisplayName          *string                                `json:"displayName,omitempty"`
+	Workload             *string                                `json:"workload,omitempty"`
+	BillingScope         *string                                `json:"billingScope,omitempty"`
+	SubscriptionID       *string                                `json:"subscriptionId,omitempty"`
+	ResellerID           *string                                `json:"resellerId,omitempty"`
+	AdditionalProperties *SubscriptionAliasAdditionalProperties `json:"additionalProperties,omitempty"`
+}
+
+type SubscriptionAliasAdditionalProperties struct {
+	ManagementGroupID   *string `json:"managementGroupId,omitempty"`
+	SubscriptionOwnerID *string `json:"subscriptionOwnerId,omitempty"`
+}
+
+type SubscriptionAlias struct {
+	autorest.Response `json:"-"`
+	ID                *string                      `json:"id,omitempty"`
+	Name              *string                      `json:"name,omitempty"`
+	Properties        *SubscriptionAliasProperties `json:"properties,omitempty"`
+}
+
+type SubscriptionAliasProperties struct {
+	SubscriptionID      *string `json:"subscriptionId,omitempty"`
+	DisplayName         *string `json:"displayName,omitempty"`
+	ProvisioningState   *string `json:"provisioningState,omitempty"`
+	BillingScope        *string `json:"billingScope,omitempty"`
+	Workload            *string `json:"workload,omitempty"`
+	ResellerID          *string `json:"resellerId,omitempty"`
+	SubscriptionOwnerID *string `json:"subscriptionOwnerId,omitempty"`
+	ManagementGroupID   *string `json:"managementGroupId,omitempty"`
+}
+
+// Create sends the alias request. The subscription is provisioned
+// asynchronously: poll Get until the provisioning state is final.
+func (client SubscriptionAliasesClient) Create(ctx context.Context, aliasName string, body SubscriptionAliasRequest) (result SubscriptionAlias, err error) {
+	req, err := autorest.CreatePreparer(
+		autorest.AsContentType("application/json; charset=utf-8"),
+		autorest.AsPut(),
+		autorest.WithBaseURL(client.BaseURI),
+		client.withAliasPath(aliasName),
+		autorest.WithJSON(body),
+		client.WithAuthorization()).Prepare((&http.Request{}).WithContext(ctx))
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Create", nil, "Failure preparing request")
+	}
+
+	resp, err := client.Send(req)
+	if err != nil {
+		result.Response = autorest.Response{Response: resp}
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Create", resp, "Failure sending request")
+	}
+
+	err = autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK, http.StatusCreated, http.StatusAccepted),
+		autorest.ByUnmarshallingJSON(&result),
+		autorest.ByClosing())
+	result.Response = autorest.Response{Response: resp}
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Create", resp, "Failure responding to request")
+	}
+
+	return result, nil
+}
+
+func (client SubscriptionAliasesClient) Get(ctx context.Context, aliasName string) (result SubscriptionAlias, err error) {
+	req, err := autorest.CreatePreparer(
+		autorest.AsGet(),
+		autorest.WithBaseURL(client.BaseURI),
+		client.withAliasPath(aliasName),
+		client.WithAuthorization()).Prepare((&http.Request{}).WithContext(ctx))
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Get", nil, "Failure preparing request")
+	}
+
+	resp, err := client.Send(req)
+	if err != nil {
+		result.Response = autorest.Response{Response: resp}
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Get", resp, "Failure sending request")
+	}
+
+	err = autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK),
+		autorest.ByUnmarshallingJSON(&result),
+		autorest.ByClosing())
+	result.Response = autorest.Response{Response: resp}
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Get", resp, "Failure responding to request")
+	}
+
+	return result, nil
+}
+
+// Delete removes the alias. The subscription it refers to is not cancelled.
+func (client SubscriptionAliasesClient) Delete(ctx context.Context, aliasName string) (result autorest.Response, err error) {
+	req, err := autorest.CreatePreparer(
+		autorest.AsDelete(),
+		autorest.WithBaseURL(client.BaseURI),
+		client.withAliasPath(aliasName),
+		client.WithAuthorization()).Prepare((&http.Request{}).WithContext(ctx))
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Delete", nil, "Failure preparing request")
+	}
+
+	resp, err := client.Send(req)
+	if err != nil {
+		result.Response = resp
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Delete", resp, "Failure sending request")
+	}
+
+	err = autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK, http.StatusNoContent),
+		autorest.ByClosing())
+	result.Response = resp
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "azurepreview.SubscriptionAliasesClient", "Delete", resp, "Failure responding to request")
+	}
+
+	return result, nil
+}
+
+func (client SubscriptionAliasesClient) withAliasPath(aliasName string) autorest.PrepareDecorator {
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.DecoratePreparer(p,
+			autorest.WithPathParameters("/providers/Microsoft.Subscription/aliases/{aliasName}", map[string]interface{}{
+				"aliasName": autorest.Encode("path", aliasName),
+			}),
+			autorest.WithQueryParameters(map[string]interface{}{
+				"api-version": subscriptionAliasAPIVersion,
+			}))
+	}
+}
@@ -0,0 +1,92 @@
+package azurepreview
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// imdsHost is the link-local address of the instance metadata service, which
+// is only reachable from the VM itself and so must never be proxied.
+const imdsHost = "169.254.169.254"
+
+// getHTTPClient returns the http.Client shared by the ARM clients, metadata
+// discovery and token acquisition. It is built once, from proxy_url,
+// ca_certificate_path and request_timeout, and then reused.
+func (c *Config) getHTTPClient() (*http.Client, error) {
+	if c.httpClient != nil {
+		return c.httpClient, nil
+	}
+
+	proxy, err := c.getProxy()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+
+	if c.CACertificatePath != "" {
+		pool, err := loadCACertificates(c.CACertificatePath)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: pool,
+		}
+	}
+
+	c.httpClient = &http.Client{
+		Transport: transport,
+		Timeout:   c.RequestTimeout,
+	}
+
+	return c.httpClient, nil
+}
+
+// getProxy returns the proxy for a request: proxy_url when it is set, and
+// otherwise the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+func (c *Config) getProxy() (func(*http.Request) (*url.URL, error), error) {
+	proxy := http.ProxyFromEnvironment
+
+	if c.ProxyURL != "" {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing proxy URL %q: %+v", c.ProxyURL, err)
+		}
+
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	return func(r *http.Request) (*url.URL, error) {
+		if r.URL.Hostname() == imdsHost {
+			return nil, nil
+		}
+
+		return proxy(r)
+	}, nil
+}
+
+// loadCACertificates returns the system certificate pool with the PEM
+// encoded certificates in path added to it.
+func loadCACertificates(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA certificate %q: %+v", path, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("error reading CA certificate %q: no PEM encoded certificates found", path)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,158 @@
+package azurepreview
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConfigGetHTTPClient_caCertificatePath(t *testing.T) {
+	server, _ := newTestMetadataServer(t)
+
+	tlsServer := httptest.NewTLSServer(server.Config.Handler)
+	defer tlsServer.Close()
+
+	dir, err := ioutil.TempDir("", "azurepreview")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
+	if err := ioutil.WriteFile(path, certificate, 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := (&Config{MetadataHost: tlsServer.URL}).getEnvironment(); err == nil {
+		t.Fatal("expected the test server's certificate to be untrusted without ca_certificate_path")
+	}
+
+	config := &Config{
+		MetadataHost:      tlsServer.URL,
+		CACertificatePath: path,
+	}
+
+	if _, err := config.getEnvironment(); err != nil {
+		t.Fatalf("expected the test server's certificate to be trusted, got: %s", err)
+	}
+}
+
+func TestConfigGetHTTPClient_invalidCACertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "azurepreview")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(path, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := &Config{
+		CACertificatePath: path,
+	}
+
+	if _, err := config.getHTTPClient(); err == nil {
+		t.Fatal("expected an error for a CA certificate file without certificates")
+	}
+}
+
+func TestConfigGetHTTPClient_proxyURL(t *testing.T) {
+	var proxied int32
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+
+		if r.URL.Host != "management.example.invalid" {
+			t.Errorf("expected a proxied request for management.example.invalid, got %q", r.URL.Host)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	config := &Config{
+		ProxyURL: proxy.URL,
+	}
+
+	client, err := config.getHTTPClient()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resp, err := client.Get("http://management.example.invalid/subscriptions")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if n := atomic.LoadInt32(&proxied); n != 1 {
+		t.Fatalf("expected 1 request through the proxy, got %d", n)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/metadata/identity/oauth2/token", imdsHost), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	proxyFunc, err := config.getProxy()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if u, _ := proxyFunc(req); u != nil {
+		t.Fatalf("expected requests to the instance metadata service not to be proxied, got %s", u)
+	}
+}
+
+func TestConfigGetHTTPClient_requestTimeout(t *testing.T) {
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	config := &Config{
+		MetadataHost:   server.URL,
+		RequestTimeout: 50 * time.Millisecond,
+	}
+
+	if _, err := config.getEnvironment(); err == nil {
+		t.Fatal("expected the metadata request to time out")
+	}
+}
+
+func TestConfigClient_sharedHTTPClient(t *testing.T) {
+	server := newTestARMServer(t)
+
+	raw := testProviderConfig(server)
+	raw["request_timeout"] = "30s"
+
+	meta := testProviderMeta(t, raw)
+
+	// Every client wraps the same http.Client in its retry policy.
+	sender, ok := meta.clientOptions.sender.(*http.Client)
+	if !ok {
+		t.Fatalf("expected the clients to use a shared http.Client, got %T", meta.clientOptions.sender)
+	}
+
+	if sender.Timeout != 30*time.Second {
+		t.Fatalf("expected a request timeout of 30s, got %s", sender.Timeout)
+	}
+
+	if _, err := meta.Subscriptions.Get(context.Background(), testSubscriptionID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -1,8 +1,15 @@
 package azurepreview
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 func expandStringSlice(input []interface{}) *[]string {
@@ -36,19 +43,99 @@ func parseSubscriptionID(input string) (string, error) {
 	return parts[2], nil
 }
 
+// subscriptionScope returns the scope of a subscription, without the leading
+// slash, as used by the scope-based Resource Manager APIs.
+func subscriptionScope(subscriptionID string) string {
+	return fmt.Sprintf("subscriptions/%s", subscriptionID)
+}
+
 type budgetResource struct {
 	Scope      string
 	BudgetName string
 }
 
+// budgetScopes match the scopes a budget can be created at, written without
+// a leading slash as in the `scope` argument.
+var budgetScopes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^subscriptions/[^/]+$`),
+	regexp.MustCompile(`(?i)^subscriptions/[^/]+/resourceGroups/[^/]+$`),
+	regexp.MustCompile(`(?i)^providers/Microsoft\.Management/managementGroups/[^/]+$`),
+	regexp.MustCompile(`(?i)^providers/Microsoft\.Billing/enrollmentAccounts/[^/]+$`),
+	regexp.MustCompile(`(?i)^providers/Microsoft\.Billing/billingAccounts/[^/]+$`),
+	regexp.MustCompile(`(?i)^providers/Microsoft\.Billing/billingAccounts/[^/]+/(departments|enrollmentAccounts|billingProfiles)/[^/]+$`),
+	regexp.MustCompile(`(?i)^providers/Microsoft\.Billing/billingAccounts/[^/]+/billingProfiles/[^/]+/invoiceSections/[^/]+$`),
+}
+
+// parseBudgetID splits a budget resource ID into its scope, without a
+// leading slash, and name.
 func parseBudgetID(input string) (*budgetResource, error) {
-	parts := strings.Split(input, "/providers/Microsoft.Consumption/budgets")
-	if len(parts) != 2 {
+	const segment = "/providers/microsoft.consumption/budgets/"
+
+	i := strings.LastIndex(strings.ToLower(input), segment)
+	if i < 0 {
 		return nil, fmt.Errorf("error parsing Budget resource ID: unexpected format: %q", input)
 	}
 
-	return &budgetResource{
-		Scope:      parts[0],
-		BudgetName: parts[1],
-	}, nil
+	scope := strings.TrimPrefix(input[:i], "/")
+	name := input[i+len(segment):]
+
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("error parsing Budget resource ID: unexpected budget name in %q", input)
+	}
+
+	for _, re := range budgetScopes {
+		if re.MatchString(scope) {
+			return &budgetResource{
+				Scope:      scope,
+				BudgetName: name,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("error parsing Budget resource ID: unsupported scope %q in %q", scope, input)
+}
+
+func parseSubscriptionAliasID(input string) (string, error) {
+	parts := strings.Split(input, "/providers/Microsoft.Subscription/aliases/")
+	if len(parts) != 2 || parts[0] != "" || parts[1] == "" || strings.Contains(parts[1], "/") {
+		return "", fmt.Errorf("error parsing Subscription Alias ID: unexpected format: %q", input)
+	}
+
+	return parts[1], nil
+}
+
+const managementGroupIDPrefix = "/providers/Microsoft.Management/managementGroups/"
+
+// parseManagementGroupID returns the name of the management group in input.
+// Resource Manager does not keep the case of the prefix consistent, so it is
+// matched case-insensitively.
+func parseManagementGroupID(input string) (string, error) {
+	if len(input) <= len(managementGroupIDPrefix) || !strings.EqualFold(input[:len(managementGroupIDPrefix)], managementGroupIDPrefix) {
+		return "", fmt.Errorf("error parsing Management Group ID: unexpected format: %q", input)
+	}
+
+	name := input[len(managementGroupIDPrefix):]
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("error parsing Management Group ID: unexpected format: %q", input)
+	}
+
+	return name, nil
+}
+
+// timeoutDiagnostics returns a diagnostic naming the timeout that ran out
+// when err was caused by the deadline on ctx, and nil for any other error.
+func timeoutDiagnostics(ctx context.Context, d *schema.ResourceData, timeout string, operation string, err error) diag.Diagnostics {
+	var timeoutErr *resource.TimeoutError
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.As(err, &timeoutErr) {
+		return nil
+	}
+
+	return diag.Diagnostics{
+		diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Timed out %s", operation),
+			Detail: fmt.Sprintf("The operation did not finish within the %s timeout of %s. It may still complete in Azure. "+
+				"If it regularly takes longer, increase `%s` in the resource's `timeouts` block.", timeout, d.Timeout(timeout), timeout),
+		},
+	}
 }
@@ -1,6 +1,12 @@
 package azurepreview
 
 import (
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -64,3 +70,112 @@ func stringIsUUID(i interface{}, k cty.Path) diag.Diagnostics {
 
 	return nil
 }
+
+func stringIsPartnerID(i interface{}, k cty.Path) diag.Diagnostics {
+	v, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected type of %q to be string", k)
+	}
+
+	if _, err := uuid.ParseUUID(strings.TrimPrefix(v, "pid-")); err != nil {
+		return diag.Errorf("expected %q to be a valid UUID, optionally prefixed with \"pid-\", got %v", k, v)
+	}
+
+	return nil
+}
+
+func stringIsBase64(i interface{}, k cty.Path) diag.Diagnostics {
+	v, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected type of %q to be string", k)
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(v); err != nil {
+		return diag.Errorf("expected %q to be a base64 encoded string", k)
+	}
+
+	return nil
+}
+
+func stringIsURL(i interface{}, k cty.Path) diag.Diagnostics {
+	v, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected type of %q to be string", k)
+	}
+
+	if v == "" {
+		return nil
+	}
+
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return diag.Errorf("expected %q to be a valid URL, got %v", k, v)
+	}
+
+	return nil
+}
+
+func stringIsDuration(i interface{}, k cty.Path) diag.Diagnostics {
+	v, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected type of %q to be string", k)
+	}
+
+	if _, err := time.ParseDuration(v); err != nil {
+		return diag.Errorf("expected %q to be a duration such as \"30s\" or \"5m\", got %v", k, v)
+	}
+
+	return nil
+}
+
+func intAtLeast(min int) schema.SchemaValidateDiagFunc {
+	return func(i interface{}, k cty.Path) diag.Diagnostics {
+		v, ok := i.(int)
+		if !ok {
+			return diag.Errorf("expected type of %s to be integer", k)
+		}
+
+		if v < min {
+			return diag.Errorf("expected %s to be at least (%d), got %d", k, min, v)
+		}
+
+		return nil
+	}
+}
+
+// billingScopeIDs match the billing scopes a subscription alias can create
+// subscriptions in: an Enterprise Agreement enrollment account, a Microsoft
+// Customer Agreement invoice section or a Microsoft Partner Agreement customer.
+var billingScopeIDs = []*regexp.Regexp{
+	regexp.MustCompile(`^/providers/Microsoft\.Billing/billingAccounts/[^/]+/enrollmentAccounts/[^/]+$`),
+	regexp.MustCompile(`^/providers/Microsoft\.Billing/billingAccounts/[^/]+/billingProfiles/[^/]+/invoiceSections/[^/]+$`),
+	regexp.MustCompile(`^/providers/Microsoft\.Billing/billingAccounts/[^/]+/customers/[^/]+$`),
+}
+
+func stringIsBillingScopeID(i interface{}, k cty.Path) diag.Diagnostics {
+	v, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected type of %q to be string", k)
+	}
+
+	for _, re := range billingScopeIDs {
+		if re.MatchString(v) {
+			return nil
+		}
+	}
+
+	return diag.Errorf("expected %q to be an enrollment account, invoice section or customer billing scope ID, got %v", k, v)
+}
+
+func stringIsManagementGroupID(i interface{}, k cty.Path) diag.Diagnostics {
+	v, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected type of %q to be string", k)
+	}
+
+	if _, err := parseManagementGroupID(v); err != nil {
+		return diag.Errorf("expected %q to be a management group ID such as %sexample, got %v", k, managementGroupIDPrefix, v)
+	}
+
+	return nil
+}